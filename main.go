@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"github.com/XayahSuSuSu/gochronize/util"
+	"os"
 )
 
 func usage() {
@@ -11,12 +12,38 @@ func usage() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("gochronize --config \"example.yml\" --history \"history.yml\"")
+	fmt.Println("gochronize verify --history \"history.yml\" [--config \"example.yml\"] [--repair]")
+	fmt.Println("gochronize import-stars --user \"name\" --config \"example.yml\" [--sync \"${latest_release}\"] [--dry-run]")
 	fmt.Println()
 	fmt.Println("Available arguments:")
 	flag.PrintDefaults()
 }
 
-var args util.Args
+func verifyUsage() {
+	fmt.Println("Verify the local files against the history, report missing, mismatched and untracked files.")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("gochronize verify --history \"history.yml\" [--config \"example.yml\"] [--repair]")
+	fmt.Println()
+	fmt.Println("Available arguments:")
+	verifyFlags.PrintDefaults()
+}
+
+func importStarsUsage() {
+	fmt.Println("Import the repos starred by the user into the targets of the configuration, the listed targets are kept.")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("gochronize import-stars --user \"name\" --config \"example.yml\" [--sync \"${latest_release}\"] [--dry-run]")
+	fmt.Println()
+	fmt.Println("Available arguments:")
+	importStarsFlags.PrintDefaults()
+}
+
+var (
+	args             util.Args
+	verifyFlags      = flag.NewFlagSet(util.CommandVerify, flag.ExitOnError)
+	importStarsFlags = flag.NewFlagSet(util.CommandImportStars, flag.ExitOnError)
+)
 
 func init() {
 	flag.BoolVar(&args.Help, "help", false, "Print the usage.")
@@ -25,10 +52,29 @@ func init() {
 	flag.StringVar(&args.Config, "config", "", "The configuration path of yaml file format.")
 	flag.StringVar(&args.History, "history", "history.yml", "The history configuration path of yaml file format.")
 	flag.Usage = usage
+
+	verifyFlags.StringVar(&args.Config, "config", "", "The configuration path of yaml file format, untracked files under parent_dir are reported if it's set.")
+	verifyFlags.StringVar(&args.History, "history", "history.yml", "The history configuration path of yaml file format.")
+	verifyFlags.BoolVar(&args.Repair, "repair", false, "Download missing or broken files again.")
+	verifyFlags.Usage = verifyUsage
+
+	importStarsFlags.StringVar(&args.User, "user", "", "The GitHub user whose starred repos are imported.")
+	importStarsFlags.StringVar(&args.Config, "config", "", "The configuration path of yaml file format, the targets are written into it.")
+	importStarsFlags.StringVar(&args.Sync, "sync", util.SyncLatestRelease, "The sync of the imported targets.")
+	importStarsFlags.BoolVar(&args.DryRun, "dry-run", false, "Print the configuration instead of writing it.")
+	importStarsFlags.Usage = importStarsUsage
 }
 
 func main() {
-	flag.Parse()
+	if len(os.Args) > 1 && os.Args[1] == util.CommandVerify {
+		args.Command = util.CommandVerify
+		_ = verifyFlags.Parse(os.Args[2:])
+	} else if len(os.Args) > 1 && os.Args[1] == util.CommandImportStars {
+		args.Command = util.CommandImportStars
+		_ = importStarsFlags.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 
 	util.ParseArgs(args)
 }
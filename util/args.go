@@ -6,18 +6,35 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
 	history       *History
-	repoIndex     = -1
+	historyMutex  sync.Mutex
 	Errors        []Err
+	errorsMutex   sync.Mutex
 	SimplifiedLog bool
 )
 
+// appendError records an error of the repo, it's safe for concurrent use.
+func appendError(user, repo, msg string) {
+	errorsMutex.Lock()
+	defer errorsMutex.Unlock()
+	Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
+}
+
 func ParseArgs(args Args) {
-	if args.Config != "" {
+	if args.Command == CommandVerify {
+		// Verify mode
+
+		os.Exit(VerifyMirror(args))
+	} else if args.Command == CommandImportStars {
+		// Import stars mode
+
+		os.Exit(ImportStars(args))
+	} else if args.Config != "" {
 		// Config mode
 
 		// Parse config
@@ -66,97 +83,34 @@ func ParseArgs(args Args) {
 		history = ReadFromHistory(args.History)
 
 		// Get http client
-		httpClient := GetHttpClient(config.ProxyHttp, config.Token, config.Timeout)
+		httpClient := GetHttpClient(config)
+		config.Targets = registerTokens(config)
+		SetMaxRateLimitWait(config.MaxRateLimitWait)
+		SetCacheDir(config.CacheDir)
+		SetMirrors(config.Mirrors)
+		ExpandTargets(httpClient, config)
+		SetMaxDownloads(maxDownloads(config))
+		PrefetchGraphQL(httpClient, config)
 
 		// Download for each config
 		exitCode := Success
-		for _, target := range config.Targets {
-			if target.Url != "" {
-				urlSplit := strings.Split(target.Url, "/")
-				if len(urlSplit) > 2 {
-					target.Repo = strings.TrimSuffix(urlSplit[len(urlSplit)-1], ".git")
-					target.User = urlSplit[len(urlSplit)-2]
-				} else {
-					Fprintfln("Failed to parse url: %s", target.Url)
-					continue
-				}
-			}
-
-			Printfln("********************************************")
-			if target.Url != "" {
-				Printfln("* url: %s", target.Url)
-			} else {
-				Printfln("* user: %s", target.User)
-				Printfln("* repo: %s", target.Repo)
-			}
-			Printfln("* sync: %s", target.Sync)
-			Printfln("* maxCount: %d", target.MaxCount)
-			Printfln("********************************************")
-
-			repoIndex = -1
-			for i, r := range history.Repos {
-				if r.User == target.User && r.Repo == target.Repo {
-					repoIndex = i
-				}
-			}
-			if repoIndex == -1 {
-				repoIndex = len(history.Repos)
-				history.Repos = append(history.Repos, HistoryRepo{
-					User: target.User,
-					Repo: target.Repo,
-				})
-			}
-
-			switch target.Sync {
-			case SyncLatestRelease:
-				err := syncLatestRelease(httpClient, &target, config, &args)
-				if err != nil {
-					exitCode = ErrorDownload
-					continue
-				}
-			case SyncLatestReleases:
-				err := syncLatestReleases(httpClient, &target, config, &args)
-				if err != nil {
-					exitCode = ErrorDownload
-					continue
-				}
-			case SyncLatestPrerelease:
-				err := syncLatestPrerelease(httpClient, &target, config, &args)
-				if err != nil {
-					exitCode = ErrorDownload
-					continue
-				}
-			case SyncLatest:
-				err := syncLatest(httpClient, &target, config, &args)
-				if err != nil {
-					exitCode = ErrorDownload
-					continue
-				}
-			case SyncFromLatestLocal, SyncReleaseFromLatestLocal, SyncPrereleaseFromLatestLocal:
-				err := syncFromLatestLocal(httpClient, &target, config, &args)
-				if err != nil {
-					exitCode = ErrorDownload
-					continue
-				}
-			case SyncAll:
-				err := syncAll(httpClient, &target, config, &args)
-				if err != nil {
-					exitCode = ErrorDownload
-					continue
-				}
-			default:
-				err := syncByTag(httpClient, &target, config, &args)
-				if err != nil {
-					exitCode = ErrorDownload
-					continue
-				}
+		var exitCodeMutex sync.Mutex
+		StartProgress(config.Concurrency)
+		RunParallel(len(config.Targets), config.Concurrency, func(i int) {
+			err := syncTarget(httpClient, config.Targets[i], config, &args)
+			if err != nil {
+				exitCodeMutex.Lock()
+				exitCode = ErrorDownload
+				exitCodeMutex.Unlock()
 			}
-		}
+		})
+		StopProgress()
 
 		Fprintfln("Errors count: %d", len(Errors))
 		for _, err := range Errors {
 			Fprintfln("User: %s, Repo: %s, err: %s", err.User, err.Repo, err.Msg)
 		}
+		PrintRateLimits()
 
 		err := SaveHistoryToYaml(args.History, history)
 		if err != nil {
@@ -183,21 +137,103 @@ func ParseArgs(args Args) {
 	os.Exit(Success)
 }
 
-func syncLatestRelease(client *http.Client, target *Target, config *Config, args *Args) error {
-	latestRelease := GetLatestRelease(client, target.User, target.Repo)
-	err := downloadRelease(client, latestRelease, target, config, args)
-	return err
+func syncTarget(client *http.Client, target Target, config *Config, args *Args) error {
+	targetUrl, err := parseTarget(&target)
+	if err != nil {
+		Fprintfln("Failed to parse url: %s, %v", target.Url, err)
+		return nil
+	}
+	if target.Source == SourceTags && len(target.SourceArchives) == 0 {
+		// A tag has nothing to download but the source archives.
+		target.SourceArchives = []string{SourceArchiveTarGz}
+	}
+	provider, err := newProvider(client, &target, apiBaseOf(&target, targetUrl, config), config)
+	if err != nil {
+		msg := fmt.Sprintf("* err: %v", err)
+		Fprintfln(msg)
+		appendError(target.User, target.Repo, msg)
+		return err
+	}
+
+	Printfln("********************************************")
+	if target.Url != "" {
+		Printfln("* url: %s", target.Url)
+	} else {
+		Printfln("* user: %s", target.User)
+		Printfln("* repo: %s", target.Repo)
+	}
+	Printfln("* sync: %s", target.Sync)
+	Printfln("* maxCount: %d", target.MaxCount)
+	Printfln("********************************************")
+
+	historyMutex.Lock()
+	historyRepoIndex(target.User, target.Repo, historySource(&target))
+	historyMutex.Unlock()
+
+	if target.Source != "" && target.Source != SourceReleases && target.MaxCount > 0 && target.Sync != SyncLatestReleases {
+		// Keep the latest [max_count] tags or runs, which is done by syncLatestReleases for releases.
+		defer pruneHistory(&target)
+	}
+	switch target.Sync {
+	case SyncLatestRelease:
+		return syncLatestRelease(client, provider, &target, config, args)
+	case SyncLatestReleases:
+		return syncLatestReleases(client, provider, &target, config, args)
+	case SyncLatestPrerelease:
+		return syncLatestPrerelease(client, provider, &target, config, args)
+	case SyncLatest:
+		return syncLatest(client, provider, &target, config, args)
+	case SyncFromLatestLocal, SyncReleaseFromLatestLocal, SyncPrereleaseFromLatestLocal:
+		return syncFromLatestLocal(client, provider, &target, config, args)
+	case SyncAll:
+		return syncAll(client, provider, &target, config, args)
+	default:
+		return syncByTag(client, provider, &target, config, args)
+	}
 }
 
-func syncLatestReleases(client *http.Client, target *Target, config *Config, args *Args) error {
-	var mErr error = nil
-	var localRepo *HistoryRepo = nil
+// historySource returns the source of the target in history, which is "" for releases. The tags and artifacts of a
+// repo are kept apart from its releases, so that the sync modes and pruning of one never see the others.
+func historySource(target *Target) string {
+	if target.Source == SourceReleases {
+		return ""
+	}
+	return target.Source
+}
+
+// defaultParentDir returns the parent dir of the target if parent_dir is left with "", which is apart for each
+// source of the repo.
+func defaultParentDir(target *Target) string {
+	if source := historySource(target); source != "" {
+		return fmt.Sprintf("./repos/%s/%s/%s", RepoName, source, TagName)
+	}
+	return fmt.Sprintf("./repos/%s/%s", RepoName, TagName)
+}
+
+// localHistoryRepo returns a copy of the repo in history, or nil if it's absent.
+func localHistoryRepo(target *Target) *HistoryRepo {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	source := historySource(target)
 	for _, r := range history.Repos {
-		if r.User == target.User && r.Repo == target.Repo {
-			localRepo = &r
-			break
+		if r.User == target.User && r.Repo == target.Repo && r.Source == source {
+			repo := r
+			repo.Releases = append([]HistoryRelease(nil), r.Releases...)
+			return &repo
 		}
 	}
+	return nil
+}
+
+func syncLatestRelease(client *http.Client, provider Provider, target *Target, config *Config, args *Args) error {
+	latestRelease := provider.GetLatestRelease(target.User, target.Repo)
+	err := downloadRelease(client, latestRelease, target, config, args)
+	return err
+}
+
+func syncLatestReleases(client *http.Client, provider Provider, target *Target, config *Config, args *Args) error {
+	var mErr error = nil
+	localRepo := localHistoryRepo(target)
 	count := 0
 	currentPage := 1
 	for currentPage != -1 {
@@ -206,7 +242,7 @@ func syncLatestReleases(client *http.Client, target *Target, config *Config, arg
 		}
 		SimplifiedPrintfln("* page: %d", currentPage)
 		var releases []Release
-		releases, currentPage = GetRelease(client, target.User, target.Repo, currentPage)
+		releases, currentPage = provider.GetRelease(target.User, target.Repo, currentPage)
 		if len(releases) >= 1 {
 			for _, release := range releases {
 				if target.MaxCount != -1 && target.MaxCount <= count {
@@ -248,41 +284,53 @@ func syncLatestReleases(client *http.Client, target *Target, config *Config, arg
 		}
 	}
 
+	pruneHistory(target)
+
+	Printfln("********************************************")
+	return mErr
+}
+
+// pruneHistory keeps the latest [max_count] releases of the target and deletes the outdated ones.
+func pruneHistory(target *Target) {
+	if target.MaxCount < 0 {
+		return
+	}
+
+	historyMutex.Lock()
 	sortHistory()
-	if localRepo != nil {
-		if len(localRepo.Releases) > target.MaxCount {
-			history.Repos[repoIndex].Releases = localRepo.Releases[:target.MaxCount]
-
-			outdatedReleases := localRepo.Releases[target.MaxCount:]
-			for i := range outdatedReleases {
-				for j := range outdatedReleases[i].Assets {
-					dir := outdatedReleases[i].Assets[j].ParentDir
-					exists, _ := PathExists(dir)
-					if exists {
-						err := os.RemoveAll(dir)
-						if err != nil {
-							Fprintfln("* err: Failed delete: %s.", dir)
-						} else {
-							Fprintfln("Delete: %s", dir)
-						}
-					}
+	index := historyRepoIndex(target.User, target.Repo, historySource(target))
+	releases := history.Repos[index].Releases
+	var outdatedReleases []HistoryRelease
+	if len(releases) > target.MaxCount {
+		history.Repos[index].Releases = releases[:target.MaxCount]
+		outdatedReleases = releases[target.MaxCount:]
+	}
+	historyMutex.Unlock()
+
+	for i := range outdatedReleases {
+		for j := range outdatedReleases[i].Assets {
+			dir := outdatedReleases[i].Assets[j].ParentDir
+			exists, _ := PathExists(dir)
+			if exists {
+				err := os.RemoveAll(dir)
+				if err != nil {
+					Fprintfln("* err: Failed delete: %s.", dir)
+				} else {
+					Fprintfln("Delete: %s", dir)
 				}
 			}
 		}
 	}
-
-	Printfln("********************************************")
-	return mErr
 }
 
-func syncLatestPrerelease(client *http.Client, target *Target, config *Config, args *Args) error {
+func syncLatestPrerelease(client *http.Client, provider Provider, target *Target, config *Config, args *Args) error {
 	var prerelease *Release = nil
 	currentPage := 1
 	for currentPage != -1 {
 		isSuccess := false
 		SimplifiedPrintfln("* page: %d", currentPage)
 		var releases []Release
-		releases, currentPage = GetRelease(client, target.User, target.Repo, currentPage)
+		releases, currentPage = provider.GetRelease(target.User, target.Repo, currentPage)
 		if len(releases) >= 1 {
 			for _, release := range releases {
 				if release.Prerelease {
@@ -312,8 +360,8 @@ func syncLatestPrerelease(client *http.Client, target *Target, config *Config, a
 	return nil
 }
 
-func syncLatest(client *http.Client, target *Target, config *Config, args *Args) error {
-	releases, _ := GetRelease(client, target.User, target.Repo, 1)
+func syncLatest(client *http.Client, provider Provider, target *Target, config *Config, args *Args) error {
+	releases, _ := provider.GetRelease(target.User, target.Repo, 1)
 	if len(releases) >= 1 {
 		err := downloadRelease(client, &releases[0], target, config, args)
 		if err != nil {
@@ -326,22 +374,16 @@ func syncLatest(client *http.Client, target *Target, config *Config, args *Args)
 	return nil
 }
 
-func syncFromLatestLocal(client *http.Client, target *Target, config *Config, args *Args) error {
+func syncFromLatestLocal(client *http.Client, provider Provider, target *Target, config *Config, args *Args) error {
 	var mErr error = nil
 	newCount := 0
 	currentPage := 1
 	for currentPage != -1 {
 		SimplifiedPrintfln("* page: %d", currentPage)
 		var releases []Release
-		releases, currentPage = GetRelease(client, target.User, target.Repo, currentPage)
+		releases, currentPage = provider.GetRelease(target.User, target.Repo, currentPage)
 		if len(releases) >= 1 {
-			var localRepo *HistoryRepo = nil
-			for _, r := range history.Repos {
-				if r.User == target.User && r.Repo == target.Repo {
-					localRepo = &r
-					break
-				}
-			}
+			localRepo := localHistoryRepo(target)
 			for _, release := range releases {
 				var latestReleaseId int64 = -1
 				if localRepo != nil {
@@ -397,13 +439,13 @@ func syncFromLatestLocal(client *http.Client, target *Target, config *Config, ar
 	return mErr
 }
 
-func syncAll(client *http.Client, target *Target, config *Config, args *Args) error {
+func syncAll(client *http.Client, provider Provider, target *Target, config *Config, args *Args) error {
 	var mErr error = nil
 	currentPage := 1
 	for currentPage != -1 {
 		SimplifiedPrintfln("* page: %d", currentPage)
 		var releases []Release
-		releases, currentPage = GetRelease(client, target.User, target.Repo, currentPage)
+		releases, currentPage = provider.GetRelease(target.User, target.Repo, currentPage)
 		if len(releases) >= 1 {
 			for _, release := range releases {
 				err := downloadRelease(client, &release, target, config, args)
@@ -419,8 +461,8 @@ func syncAll(client *http.Client, target *Target, config *Config, args *Args) er
 	return mErr
 }
 
-func syncByTag(client *http.Client, target *Target, config *Config, args *Args) error {
-	latestRelease := GetReleaseByTag(client, target.User, target.Repo, target.Sync)
+func syncByTag(client *http.Client, provider Provider, target *Target, config *Config, args *Args) error {
+	latestRelease := provider.GetReleaseByTag(target.User, target.Repo, target.Sync)
 	var err error
 	if latestRelease != nil {
 		err = downloadRelease(client, latestRelease, target, config, args)
@@ -430,7 +472,8 @@ func syncByTag(client *http.Client, target *Target, config *Config, args *Args)
 	return err
 }
 
-func handleVars(old, fileName, repoName, tagName, releaseName, createdAtStr, updatedAtStr, timeFormat string) string {
+// handleVars replaces the vars in old, vars are the extra vars of the release, e.g. ${run_number}.
+func handleVars(old, fileName, repoName, tagName, releaseName, createdAtStr, updatedAtStr, timeFormat string, vars map[string]string) string {
 	str := strings.ReplaceAll(old, FileName, fileName)
 	str = strings.ReplaceAll(str, RepoName, repoName)
 	str = strings.ReplaceAll(str, TagName, tagName)
@@ -469,6 +512,13 @@ func handleVars(old, fileName, repoName, tagName, releaseName, createdAtStr, upd
 	if err == nil {
 		str = strings.ReplaceAll(str, matchedVar, matchedStr)
 	}
+	for variable, value := range vars {
+		str = strings.ReplaceAll(str, variable, value)
+		matchedVar, matchedStr, err = MatchCustomRegex(variable, str, value)
+		if err == nil {
+			str = strings.ReplaceAll(str, matchedVar, matchedStr)
+		}
+	}
 	return str
 }
 
@@ -480,19 +530,34 @@ func downloadRelease(client *http.Client, release *Release, target *Target, conf
 		SimplifiedPrintfln("* exclusion: [%s]", strings.Join(target.Exclusion, ", "))
 
 		historyRelease := HistoryRelease{Name: release.Name, TagName: release.TagName}
-		historyReleaseIndex := -1
-		for i, r := range history.Repos[repoIndex].Releases {
+		historyMutex.Lock()
+		repoIndex := historyRepoIndex(target.User, target.Repo, historySource(target))
+		for _, r := range history.Repos[repoIndex].Releases {
 			if r.Name == release.Name && r.TagName == release.TagName {
 				historyRelease = r
-				historyReleaseIndex = i
+				historyRelease.Assets = append([]HistoryAsset(nil), r.Assets...)
 			}
 		}
+		historyMutex.Unlock()
 		historyRelease.Id = release.Id
 		historyRelease.Prerelease = release.Prerelease
 		historyRelease.CreatedAt = release.CreatedAt
 		historyRelease.PublishedAt = release.PublishedAt
 
-		for _, asset := range release.Assets {
+		var signatures map[string][]byte
+		if target.Verify.Signature.Type != "" && !args.DryRun {
+			signatures = fetchSignatures(client, release, target)
+		}
+		// The assets covered by a signed checksum asset are verified by their checksums, even if checksum is false.
+		var checksums map[string]string
+		var signedChecksums map[string]bool
+		if (target.Verify.Checksum || signatures != nil) && !args.DryRun {
+			checksums, signedChecksums = fetchChecksums(client, release, target, signatures)
+		}
+
+		var jobs []downloadJob
+		assets := append(append([]Asset(nil), release.Assets...), sourceArchiveAssets(release, target)...)
+		for _, asset := range assets {
 			url := asset.BrowserDownloadURL
 			name := asset.Name
 			fileName := target.FileName
@@ -505,19 +570,19 @@ func downloadRelease(client *http.Client, release *Release, target *Target, conf
 			if fileName == "" {
 				fileName = FileName
 			}
-			fileName = handleVars(fileName, name, target.Repo, release.TagName, release.Name, asset.CreatedAt, asset.UpdatedAt, config.TimeFormat)
+			fileName = handleVars(fileName, name, target.Repo, release.TagName, release.Name, asset.CreatedAt, asset.UpdatedAt, config.TimeFormat, release.Vars)
 
 			if parentDir == "" {
-				parentDir = fmt.Sprintf("./repos/%s/%s", RepoName, TagName)
+				parentDir = defaultParentDir(target)
 			}
-			parentDir = handleVars(parentDir, name, target.Repo, release.TagName, release.Name, asset.CreatedAt, asset.UpdatedAt, config.TimeFormat)
+			parentDir = handleVars(parentDir, name, target.Repo, release.TagName, release.Name, asset.CreatedAt, asset.UpdatedAt, config.TimeFormat, release.Vars)
 			parentDir = strings.TrimSuffix(parentDir, "/")
 
 			for i := range categories {
 				if categories[i].ParentDir == "" {
-					categories[i].ParentDir = fmt.Sprintf("./repos/%s/%s", RepoName, TagName)
+					categories[i].ParentDir = defaultParentDir(target)
 				}
-				categories[i].ParentDir = handleVars(categories[i].ParentDir, name, target.Repo, release.TagName, release.Name, asset.CreatedAt, asset.UpdatedAt, config.TimeFormat)
+				categories[i].ParentDir = handleVars(categories[i].ParentDir, name, target.Repo, release.TagName, release.Name, asset.CreatedAt, asset.UpdatedAt, config.TimeFormat, release.Vars)
 				categories[i].ParentDir = strings.TrimSuffix(categories[i].ParentDir, "/")
 
 				matched, err := MatchString(fileName, categories[i].Key)
@@ -569,54 +634,82 @@ func downloadRelease(client *http.Client, release *Release, target *Target, conf
 				historyAsset.UpdatedAt = asset.UpdatedAt
 			}
 
-			if historyAssetIndex == -1 {
-				historyRelease.Assets = append(historyRelease.Assets, historyAsset)
-			} else {
-				historyRelease.Assets[historyAssetIndex] = historyAsset
+			if historyAssetIndex != -1 {
 				SimplifiedPrintfln("%s has already been in history config.", historyAsset.Name)
 				if !target.Overwrite {
+					historyRelease.Assets[historyAssetIndex] = historyAsset
 					continue
 				}
 			}
 
 			if !args.DryRun {
-				count := config.Retries
-				for count > 0 {
-					SimplifiedPrintfln("* info: Trying to create: %s.", parentDir)
-					err := os.MkdirAll(parentDir, os.ModePerm)
-					if err != nil {
-						return err
-					}
-					dst := fmt.Sprintf("%s/%s", parentDir, fileName)
-					SimplifiedPrintfln("* info: Download: %s to %s.", name, dst)
-					err = Download(client, url, dst)
-					if err != nil {
-						Fprintfln("%v", err)
-						SimplifiedPrintfln("* info: Retry: %d", config.Retries-count+1)
-						count--
-						if count == 0 {
-							msg := fmt.Sprintf("* err: Failed to download %s within %d times, trying to delete tmp file: %s.", name, config.Retries, dst)
-							Fprintfln(msg)
-							Errors = append(Errors, Err{User: target.User, Repo: target.Repo, Msg: msg})
-							err := os.Remove(dst)
-							if err != nil {
-								Fprintfln("* err: Failed delete: %s.", name)
-							}
-						}
-					} else {
-						break
-					}
+				// The asset is recorded in history only if it's downloaded successfully.
+				job := downloadJob{name: name, url: url, apiUrl: asset.Url, size: asset.Size, parentDir: parentDir, fileName: fileName, historyAsset: historyAsset, historyAssetIndex: historyAssetIndex}
+				covered := signedChecksums[name]
+				if (target.Verify.Checksum || covered) && !asset.Source && !isChecksumAsset(name, target) {
+					job.checksums = checksums
+				}
+				if signatures != nil && !covered && !asset.Source && !isSignatureAsset(name, target) {
+					job.signatures = signatures
 				}
+				jobs = append(jobs, job)
 			} else {
+				if historyAssetIndex == -1 {
+					historyRelease.Assets = append(historyRelease.Assets, historyAsset)
+				} else {
+					historyRelease.Assets[historyAssetIndex] = historyAsset
+				}
 				Printfln("* info: Dry-run is enabled and skip download.")
 			}
 		}
 
+		concurrency := target.Concurrency
+		if concurrency <= 0 {
+			concurrency = config.Concurrency
+		}
+		var mErr error = nil
+		var mErrMutex sync.Mutex
+		RunParallel(len(jobs), concurrency, func(i int) {
+			free := acquireDownload()
+			defer free()
+			err := downloadAsset(client, &jobs[i], target, config)
+			if err != nil {
+				mErrMutex.Lock()
+				mErr = err
+				mErrMutex.Unlock()
+			}
+		})
+		if mErr != nil {
+			return mErr
+		}
+		failed := false
+		for _, job := range jobs {
+			if !job.done {
+				failed = true
+			} else if job.historyAssetIndex == -1 {
+				historyRelease.Assets = append(historyRelease.Assets, job.historyAsset)
+			} else {
+				historyRelease.Assets[job.historyAssetIndex] = job.historyAsset
+			}
+		}
+
+		historyMutex.Lock()
+		repoIndex = historyRepoIndex(target.User, target.Repo, historySource(target))
+		historyReleaseIndex := -1
+		for i, r := range history.Repos[repoIndex].Releases {
+			if r.Name == release.Name && r.TagName == release.TagName {
+				historyReleaseIndex = i
+			}
+		}
 		if historyReleaseIndex == -1 {
-			history.Repos[repoIndex].Releases = append(history.Repos[repoIndex].Releases, historyRelease)
+			// A new release with failed assets isn't recorded, so that it's synchronized again next time.
+			if !failed {
+				history.Repos[repoIndex].Releases = append(history.Repos[repoIndex].Releases, historyRelease)
+			}
 		} else {
 			history.Repos[repoIndex].Releases[historyReleaseIndex] = historyRelease
 		}
+		historyMutex.Unlock()
 	} else {
 		return fmt.Errorf("failed to get the latest release")
 	}
@@ -625,6 +718,86 @@ func downloadRelease(client *http.Client, release *Release, target *Target, conf
 	return nil
 }
 
+type downloadJob struct {
+	name              string
+	url               string
+	apiUrl            string
+	size              int64
+	parentDir         string
+	fileName          string
+	historyAsset      HistoryAsset
+	historyAssetIndex int
+	checksums         map[string]string
+	signatures        map[string][]byte
+	done              bool
+}
+
+// downloadAsset downloads the job from its sources within [retries] times, resuming the partial file left by the
+// last one. Only failing to create the parent dir is returned as an error.
+func downloadAsset(client *http.Client, job *downloadJob, target *Target, config *Config) error {
+	sources := assetSources(job.url, job.apiUrl)
+
+	count := config.Retries
+	for count > 0 {
+		SimplifiedPrintfln("* info: Trying to create: %s.", job.parentDir)
+		err := os.MkdirAll(job.parentDir, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		dst := fmt.Sprintf("%s/%s", job.parentDir, job.fileName)
+		SimplifiedPrintfln("* info: Download: %s to %s.", job.name, dst)
+		var verify func(file string, result *DownloadResult) error = nil
+		if job.checksums != nil || job.signatures != nil {
+			verify = func(file string, result *DownloadResult) error {
+				if job.signatures != nil {
+					err := verifyFileSignature(file, job.name, job.signatures, target)
+					if err != nil {
+						return err
+					}
+				}
+				if job.checksums != nil {
+					return verifyChecksum(result.Sha256, job.name, job.checksums, target)
+				}
+				return nil
+			}
+		}
+		result, err := Download(client, sources[0].url, sources[0].header, dst, job.size, verify)
+		if err != nil && nextAssetSource(sources, err) {
+			SimplifiedPrintfln("* info: Failed to download from: %s, %v, try the next source.", sources[0].url, err)
+			if sources[0].mirror && !IsRetryable(err) {
+				removePart(dst + PartSuffix)
+			}
+			sources = sources[1:]
+			continue
+		}
+		if err == nil {
+			job.historyAsset.Sha256 = result.Sha256
+			job.historyAsset.Size = result.Size
+			job.historyAsset.DownloadedAt = time.Now().UTC().Format(time.RFC3339)
+			job.historyAsset.ServedBy = result.Host
+			job.done = true
+			break
+		}
+
+		Fprintfln("%v", err)
+		if !IsRetryable(err) {
+			msg := fmt.Sprintf("* err: Failed to download %s, %v.", job.name, err)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+			removePart(dst + PartSuffix)
+			break
+		}
+		SimplifiedPrintfln("* info: Retry: %d", config.Retries-count+1)
+		count--
+		if count == 0 {
+			msg := fmt.Sprintf("* err: Failed to download %s within %d times, the partial file is kept for resuming: %s.", job.name, config.Retries, dst+PartSuffix)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+		}
+	}
+	return nil
+}
+
 func PathExists(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if err == nil {
@@ -0,0 +1,169 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// runsPerPage is small, since the artifacts of each run listed are requested, even if only the latest one is used.
+const runsPerPage = 10
+
+// Artifacts lists the successful runs of a workflow as releases, with their artifacts as assets.
+type Artifacts struct {
+	client   *http.Client
+	apiBase  string
+	workflow string
+	branch   string
+	pager    *pager
+}
+
+type workflowRun struct {
+	Id        int64  `json:"id"`
+	Name      string `json:"name"`
+	RunNumber int64  `json:"run_number"`
+	HeadSha   string `json:"head_sha"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type workflowArtifact struct {
+	Id                 int64  `json:"id"`
+	Name               string `json:"name"`
+	ArchiveDownloadUrl string `json:"archive_download_url"`
+	Expired            bool   `json:"expired"`
+	CreatedAt          string `json:"created_at"`
+	UpdatedAt          string `json:"updated_at"`
+}
+
+func NewArtifacts(client *http.Client, apiBase, workflow, branch string) *Artifacts {
+	return &Artifacts{client: client, apiBase: apiBase, workflow: workflow, branch: branch, pager: newPager()}
+}
+
+// toRelease maps the run onto Release, expired artifacts are skipped and the sizes of the zips are left unknown.
+func (p *Artifacts) toRelease(user, repo string, run *workflowRun) (*Release, error) {
+	release := &Release{
+		Name:        fmt.Sprintf("%s #%d", run.Name, run.RunNumber),
+		TagName:     strconv.FormatInt(run.RunNumber, 10),
+		Id:          run.Id,
+		CreatedAt:   run.CreatedAt,
+		PublishedAt: run.UpdatedAt,
+		Vars: map[string]string{
+			RunNumber: strconv.FormatInt(run.RunNumber, 10),
+			HeadSha:   run.HeadSha,
+		},
+	}
+
+	api := fmt.Sprintf("%s/repos/%s/%s/actions/runs/%d/artifacts?per_page=%d", p.apiBase, user, repo, run.Id, MaxPerPage)
+	for api != "" {
+		var page struct {
+			Artifacts []workflowArtifact `json:"artifacts"`
+		}
+		resp, err := GetJson(p.client, api, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, artifact := range page.Artifacts {
+			if artifact.Expired {
+				SimplifiedPrintfln("* info: Artifact: %s of run: %d is expired, skip.", artifact.Name, run.RunNumber)
+				continue
+			}
+			release.Assets = append(release.Assets, Asset{
+				Id:                 artifact.Id,
+				Name:               artifact.Name + ".zip",
+				BrowserDownloadURL: artifact.ArchiveDownloadUrl,
+				CreatedAt:          artifact.CreatedAt,
+				UpdatedAt:          artifact.UpdatedAt,
+			})
+		}
+		api = NextLink(resp.Header.Values("Link"), resp.Request.URL)
+	}
+	return release, nil
+}
+
+// listRuns returns the successful runs of the page and the next page.
+func (p *Artifacts) listRuns(user, repo string, page int) ([]workflowRun, int, error) {
+	query := url.Values{}
+	query.Set("status", "success")
+	query.Set("per_page", strconv.Itoa(runsPerPage))
+	if p.branch != "" {
+		query.Set("branch", p.branch)
+	}
+	api := fmt.Sprintf("%s/repos/%s/%s/actions/workflows/%s/runs?%s", p.apiBase, user, repo, url.PathEscape(p.workflow), query.Encode())
+	var runs struct {
+		WorkflowRuns []workflowRun `json:"workflow_runs"`
+	}
+	resp, err := GetJson(p.client, p.pager.url(page, api), &runs)
+	if err != nil {
+		return nil, -1, err
+	}
+	return runs.WorkflowRuns, p.pager.nextLink(page, len(runs.WorkflowRuns), resp), nil
+}
+
+func (p *Artifacts) GetRelease(user, repo string, page int) ([]Release, int) {
+	runs, nextPage, err := p.listRuns(user, repo, page)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to list runs of workflow: %s, %v", p.workflow, err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+
+	var releases []Release
+	for i := range runs {
+		release, err := p.toRelease(user, repo, &runs[i])
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to list artifacts of run: %d, %v", runs[i].RunNumber, err)
+			Fprintfln(msg)
+			appendError(user, repo, msg)
+			continue
+		}
+		releases = append(releases, *release)
+	}
+	return releases, nextPage
+}
+
+func (p *Artifacts) GetLatestRelease(user, repo string) *Release {
+	releases, _ := p.GetRelease(user, repo, 1)
+	if len(releases) == 0 {
+		msg := fmt.Sprintf("* err: No successful runs of workflow: %s.", p.workflow)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	return &releases[0]
+}
+
+// GetReleaseByTag returns the run whose run number is tag.
+func (p *Artifacts) GetReleaseByTag(user, repo, tag string) *Release {
+	page := 1
+	for page != -1 {
+		var runs []workflowRun
+		var err error
+		runs, page, err = p.listRuns(user, repo, page)
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to list runs of workflow: %s, %v", p.workflow, err)
+			Fprintfln(msg)
+			appendError(user, repo, msg)
+			return nil
+		}
+		for i := range runs {
+			if strconv.FormatInt(runs[i].RunNumber, 10) != tag {
+				continue
+			}
+			release, err := p.toRelease(user, repo, &runs[i])
+			if err != nil {
+				msg := fmt.Sprintf("* err: Failed to list artifacts of run: %s, %v", tag, err)
+				Fprintfln(msg)
+				appendError(user, repo, msg)
+				return nil
+			}
+			return release
+		}
+	}
+	msg := fmt.Sprintf("* err: Run not found: %s.", tag)
+	Fprintfln(msg)
+	appendError(user, repo, msg)
+	return nil
+}
@@ -0,0 +1,233 @@
+package util
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VerifyMirror audits the local files against the history and returns the exit code, which is ErrorVerify if any
+// file is missing, mismatched or untracked. Broken files are downloaded again if args.Repair is enabled.
+func VerifyMirror(args Args) int {
+	var config *Config = nil
+	if args.Config != "" {
+		config = ReadFromConfig(args.Config)
+		SimplifiedLog = config.SimplifiedLog
+	}
+	history = ReadFromHistory(args.History)
+	Printfln("Time: %s", time.Now().Format("2006-01-02 15:04:05"))
+
+	var client *http.Client = nil
+	retries := 1
+	if args.Repair {
+		if config != nil {
+			client = GetHttpClient(config)
+			registerTokens(config)
+			SetMaxRateLimitWait(config.MaxRateLimitWait)
+			if config.Retries > retries {
+				retries = config.Retries
+			}
+		} else {
+			client = GetHttpClient(&Config{})
+		}
+	}
+
+	issues := 0
+	repaired := 0
+	tracked := map[string]bool{}
+	for i := range history.Repos {
+		repo := &history.Repos[i]
+		for j := range repo.Releases {
+			release := &repo.Releases[j]
+			for k := range release.Assets {
+				asset := &release.Assets[k]
+				path := fmt.Sprintf("%s/%s", asset.ParentDir, asset.FileName)
+				tracked[cleanPath(path)] = true
+
+				problem := checkHistoryAsset(path, asset)
+				if problem == "" {
+					continue
+				}
+				Fprintfln("* err: %s/%s %s: %s, %s.", repo.User, repo.Repo, release.TagName, path, problem)
+				if args.Repair && repairHistoryAsset(client, path, asset, retries) {
+					Printfln("* info: Repaired: %s.", path)
+					repaired++
+				} else {
+					issues++
+				}
+			}
+		}
+	}
+
+	if config != nil {
+		for _, root := range parentDirRoots(config) {
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return err
+				}
+				if d.IsDir() || tracked[cleanPath(path)] || cleanPath(path) == cleanPath(args.History) {
+					return nil
+				}
+				if strings.HasSuffix(path, PartSuffix) || strings.HasSuffix(path, PartSuffix+ValidatorSuffix) {
+					// Partial files are kept by failed downloads for resuming, they aren't issues of the mirror.
+					SimplifiedPrintfln("* info: Partial download: %s.", path)
+					return nil
+				}
+				Fprintfln("* err: Untracked: %s.", path)
+				issues++
+				return nil
+			})
+			if err != nil {
+				Fprintfln("* err: Failed to walk: %s, %v", root, err)
+				issues++
+			}
+		}
+	} else {
+		SimplifiedPrintfln("* info: No config specified, skip checking untracked files.")
+	}
+
+	if repaired > 0 {
+		err := SaveHistoryToYaml(args.History, history)
+		if err != nil {
+			Fprintfln("Failed to save history config, %v", err)
+			return ErrorIO
+		}
+	}
+
+	Printfln("Repaired count: %d", repaired)
+	Fprintfln("Issues count: %d", issues)
+	if issues > 0 {
+		return ErrorVerify
+	}
+	return Success
+}
+
+// checkHistoryAsset returns the problem of the local file, or "" if it's what was downloaded.
+// Only the existence is checked for the assets recorded without size and SHA-256 by older versions.
+func checkHistoryAsset(path string, asset *HistoryAsset) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "missing"
+		}
+		return err.Error()
+	}
+	if !info.Mode().IsRegular() {
+		return "not a regular file"
+	}
+	if asset.Size > 0 && info.Size() != asset.Size {
+		return fmt.Sprintf("size mismatch, expected: %d, actual: %d", asset.Size, info.Size())
+	}
+	if asset.Sha256 != "" {
+		digest, err := FileSha256(path)
+		if err != nil {
+			return err.Error()
+		}
+		if digest != asset.Sha256 {
+			return fmt.Sprintf("hash mismatch, expected: %s, actual: %s", asset.Sha256, digest)
+		}
+	}
+	return ""
+}
+
+// repairHistoryAsset downloads the asset again from its BrowserDownloadURL and updates the history record.
+func repairHistoryAsset(client *http.Client, path string, asset *HistoryAsset, retries int) bool {
+	err := os.MkdirAll(asset.ParentDir, os.ModePerm)
+	if err != nil {
+		Fprintfln("* err: Failed to mkdir: %s, %v", asset.ParentDir, err)
+		return false
+	}
+
+	verify := func(file string, result *DownloadResult) error {
+		if asset.Sha256 != "" && result.Sha256 != asset.Sha256 {
+			return &ChecksumError{Name: asset.Name, Expected: asset.Sha256, Actual: result.Sha256}
+		}
+		return nil
+	}
+	for count := 0; count < retries; count++ {
+		SimplifiedPrintfln("* info: Download: %s to %s.", asset.BrowserDownloadURL, path)
+		result, err := Download(client, asset.BrowserDownloadURL, nil, path, asset.Size, verify)
+		if err == nil {
+			asset.Sha256 = result.Sha256
+			asset.Size = result.Size
+			asset.DownloadedAt = time.Now().UTC().Format(time.RFC3339)
+			return true
+		}
+		Fprintfln("%v", err)
+		if !IsRetryable(err) {
+			removePart(path + PartSuffix)
+			break
+		}
+	}
+	return false
+}
+
+// parentDirRoots returns the static prefixes of parent_dir of all targets and their categories, e.g. "./repos" for
+// "./repos/${repo_name}/${tag_name}".
+func parentDirRoots(config *Config) []string {
+	var roots []string
+	seen := map[string]bool{}
+	add := func(target *Target, parentDir string) {
+		if parentDir == "" {
+			parentDir = defaultParentDir(target)
+		}
+		root := parentDir
+		if index := strings.Index(root, "${"); index != -1 {
+			root = filepath.Dir(root[:index] + "_")
+			if root == "." || root == filepath.Dir(root) {
+				// A fully templated parent_dir has no static dir, walking "." or "/" reports everything.
+				SimplifiedPrintfln("* info: Skip checking untracked files of: %s, it has no static dir.", parentDir)
+				return
+			}
+		}
+		root = filepath.Clean(root)
+		if !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+	for _, target := range config.Targets {
+		if isDynamicTarget(&target) {
+			// The expanded repos are synchronized with the template.
+			if target.Template == nil {
+				add(&Target{}, "")
+				continue
+			}
+			target = *target.Template
+		}
+		add(&target, target.ParentDir)
+		for _, category := range target.Categories {
+			add(&target, category.ParentDir)
+		}
+	}
+
+	// Skip the roots nested in another root.
+	var result []string
+	for _, root := range roots {
+		nested := false
+		for _, other := range roots {
+			if other != root && strings.HasPrefix(cleanPath(root), cleanPath(other)+string(filepath.Separator)) {
+				nested = true
+			}
+		}
+		if !nested {
+			result = append(result, root)
+		}
+	}
+	return result
+}
+
+func cleanPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
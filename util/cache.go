@@ -0,0 +1,111 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// cachedHeaders are the response headers kept in the cache, the providers read pagination from them.
+var cachedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Link", "X-Next-Page", "X-Total", "X-Total-Count", "X-Total-Pages"}
+
+// cacheDir is the dir of the API response cache, caching is disabled if it's "".
+var cacheDir = ""
+
+type cacheEntry struct {
+	Url    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// SetCacheDir sets the dir of the API response cache, caching is disabled if dir is "".
+func SetCacheDir(dir string) {
+	cacheDir = dir
+}
+
+// cachePath returns the cache file of the url requested with the authorization, so a response is never reused for
+// another token.
+func cachePath(url, authorization string) string {
+	sum := sha256.Sum256([]byte(authorization + "\n" + url))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// GetCached is Get with a conditional request of the cached ETag or Last-Modified, a 304 returns the cached body.
+func GetCached(client *http.Client, url string) (*http.Response, error) {
+	if cacheDir == "" {
+		return Get(client, url)
+	}
+
+	req, err := NewRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	path := cachePath(url, req.Header.Get("Authorization"))
+	var entry *cacheEntry = nil
+	data, err := os.ReadFile(path)
+	if err == nil {
+		entry = &cacheEntry{}
+		err = json.Unmarshal(data, entry)
+		if err != nil || entry.Url != url {
+			entry = nil
+		}
+	}
+	if entry != nil {
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		SimplifiedPrintfln("* info: Not modified: %s.", url)
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		for key, values := range entry.Header {
+			resp.Header[key] = values
+		}
+		resp.ContentLength = int64(len(entry.Body))
+		resp.Body = io.NopCloser(bytes.NewReader(entry.Body))
+		return resp, nil
+	}
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry = &cacheEntry{Url: url, Header: http.Header{}, Body: body}
+	for _, key := range cachedHeaders {
+		if value := resp.Header.Values(key); len(value) > 0 {
+			entry.Header[key] = value
+		}
+	}
+	data, err = json.Marshal(entry)
+	if err == nil {
+		err = os.MkdirAll(cacheDir, os.ModePerm)
+	}
+	if err == nil {
+		err = WriteFileAtomic(path, data, 0600)
+	}
+	if err != nil {
+		Fprintfln("* err: Failed to cache: %s, %v", url, err)
+	}
+	return resp, nil
+}
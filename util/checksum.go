@@ -0,0 +1,182 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultChecksumAssets match the checksum assets published by most repos, e.g. "SHA256SUMS", "checksums.txt",
+// "app_1.0.0_checksums.txt" of goreleaser and "app.tar.gz.sha256".
+var DefaultChecksumAssets = []string{`(?i)^sha256sums(\.txt)?$`, `(?i)checksums?\.txt$`, `(?i)\.sha256(sum)?$`}
+
+// maxChecksumSize limits the checksum assets read into memory.
+const maxChecksumSize = 4 << 20
+
+var (
+	coreutilsChecksumRegex = regexp.MustCompile(`^([0-9a-fA-F]{64})\s+\*?(.+)$`)
+	bsdChecksumRegex       = regexp.MustCompile(`^SHA256\s*\((.+)\)\s*=\s*([0-9a-fA-F]{64})$`)
+	bareChecksumRegex      = regexp.MustCompile(`^([0-9a-fA-F]{64})$`)
+)
+
+// ChecksumError means the SHA-256 of the downloaded file doesn't match the published one.
+type ChecksumError struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("no checksum found for %s", e.Name)
+	}
+	return fmt.Sprintf("checksum mismatch of %s, expected: %s, actual: %s", e.Name, e.Expected, e.Actual)
+}
+
+// Retryable is always false, the published checksum won't change by downloading again.
+func (e *ChecksumError) Retryable() bool {
+	return false
+}
+
+// isChecksumAsset reports whether the asset name matches the checksum asset patterns of the target.
+func isChecksumAsset(name string, target *Target) bool {
+	patterns := target.Verify.ChecksumAssets
+	if len(patterns) == 0 {
+		patterns = DefaultChecksumAssets
+	}
+	for _, pattern := range patterns {
+		matched, _ := MatchString(name, pattern)
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseChecksums parses checksum files of coreutils ("hex  name" or "hex *name"), BSD ("SHA256 (name) = hex") and
+// goreleaser (same as coreutils) style. A bare hex digest, as in per-file "*.sha256" assets, is stored with fallbackName.
+func ParseChecksums(reader io.Reader, fallbackName string) (map[string]string, error) {
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if matches := bsdChecksumRegex.FindStringSubmatch(line); matches != nil {
+			checksums[path.Base(matches[1])] = strings.ToLower(matches[2])
+		} else if matches := coreutilsChecksumRegex.FindStringSubmatch(line); matches != nil {
+			checksums[path.Base(strings.TrimSpace(matches[2]))] = strings.ToLower(matches[1])
+		} else if matches := bareChecksumRegex.FindStringSubmatch(line); matches != nil && fallbackName != "" {
+			checksums[fallbackName] = strings.ToLower(matches[1])
+		}
+	}
+	return checksums, scanner.Err()
+}
+
+// fetchChecksums reads the checksum assets of the release, signed reports the names from a signature verified one.
+func fetchChecksums(client *http.Client, release *Release, target *Target, signatures map[string][]byte) (checksums map[string]string, signed map[string]bool) {
+	checksums = map[string]string{}
+	signed = map[string]bool{}
+	for _, asset := range release.Assets {
+		if !isChecksumAsset(asset.Name, target) {
+			continue
+		}
+
+		SimplifiedPrintfln("* info: Read checksums from: %s.", asset.Name)
+		resp, err := getAsset(client, asset.BrowserDownloadURL, asset.Url)
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to get: %s, %v", asset.BrowserDownloadURL, err)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+			continue
+		}
+
+		// "app.tar.gz.sha256" may contain only the digest of "app.tar.gz".
+		fallbackName := ""
+		lowerName := strings.ToLower(asset.Name)
+		for _, suffix := range []string{".sha256", ".sha256sum"} {
+			if strings.HasSuffix(lowerName, suffix) {
+				fallbackName = asset.Name[:len(asset.Name)-len(suffix)]
+			}
+		}
+		content, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumSize))
+		resp.Body.Close()
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to read checksums: %s, %v", asset.Name, err)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+			continue
+		}
+		verified := false
+		if signatures != nil {
+			_, verified = signatures[asset.Name]
+			err = verifySignature(bytes.NewReader(content), asset.Name, signatures, target)
+			if err != nil {
+				msg := fmt.Sprintf("* err: Untrusted checksums: %s, %v", asset.Name, err)
+				Fprintfln(msg)
+				appendError(target.User, target.Repo, msg)
+				continue
+			}
+		}
+		parsed, err := ParseChecksums(bytes.NewReader(content), fallbackName)
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to parse checksums: %s, %v", asset.Name, err)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+			continue
+		}
+		for name, sum := range parsed {
+			if signed[name] && !verified {
+				continue
+			}
+			checksums[name] = sum
+			if verified {
+				signed[name] = true
+			}
+		}
+	}
+	return checksums, signed
+}
+
+// FileSha256 returns the hex SHA-256 of the file.
+func FileSha256(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyChecksum checks the digest of the downloaded asset name against its published checksum.
+func verifyChecksum(digest, name string, checksums map[string]string, target *Target) error {
+	expected, ok := checksums[name]
+	if !ok {
+		if target.Verify.Required {
+			return &ChecksumError{Name: name}
+		}
+		SimplifiedPrintfln("* info: No checksum found for %s, skip verifying.", name)
+		return nil
+	}
+
+	if digest != expected {
+		return &ChecksumError{Name: name, Expected: expected, Actual: digest}
+	}
+	SimplifiedPrintfln("* info: Checksum verified: %s.", name)
+	return nil
+}
@@ -18,6 +18,66 @@ type Target struct {
 	FileName   string     `yaml:"file_name"`
 	Exclusion  []string   `yaml:"exclusion"`
 	Categories []Category `yaml:"categories"`
+
+	// Provider is one of github, gitea, forgejo, gitlab and http-index, it's detected from the host of url if it's
+	// empty, except http-index.
+	Provider string `yaml:"provider"`
+	// VersionRegex groups the files of http-index into releases by the matched version, see HttpIndex.
+	VersionRegex string `yaml:"version_regex"`
+	// ApiBase overrides the API base detected from url, e.g. "https://ghe.corp/api/v3".
+	ApiBase string `yaml:"api_base"`
+	// Token is only sent to the hosts of the API base and url of this target.
+	Token string `yaml:"token"`
+	// Concurrency overrides Config.Concurrency for the assets of this target, the assets of all targets downloaded at
+	// the same time are limited by the largest one of them, see maxDownloads.
+	Concurrency int    `yaml:"concurrency"`
+	Verify      Verify `yaml:"verify"`
+	// Source is releases, tags or artifacts, tags and the runs of the workflow are synchronized as releases, see Tags
+	// and Artifacts.
+	Source string `yaml:"source"`
+	// Workflow is the workflow file name and Branch is the optional branch of the runs of artifacts.
+	Workflow string `yaml:"workflow"`
+	Branch   string `yaml:"branch"`
+	// SourceArchives are the formats of the source archives downloaded with the assets, zip and tar.gz.
+	SourceArchives []string `yaml:"source_archives"`
+
+	// Owner expands the target into the repos of the GitHub user or organization, see ExpandTargets.
+	Owner string `yaml:"owner"`
+	// Stars expands the target into the repos starred by the GitHub user, see ExpandTargets.
+	Stars string `yaml:"stars"`
+	// Include and Exclude are regexes of the repo names of the owner, or "user/repo" of the stars.
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Archived and Forks include the archived and forked repos.
+	Archived bool `yaml:"archived"`
+	Forks    bool `yaml:"forks"`
+	// Template is the settings of the expanded targets.
+	Template *Target `yaml:"template"`
+}
+
+type Verify struct {
+	// Checksum verifies the assets against the checksum assets of the same release.
+	Checksum bool `yaml:"checksum"`
+	// ChecksumAssets are regexes of the checksum asset names, DefaultChecksumAssets is used if it's empty.
+	ChecksumAssets []string `yaml:"checksum_assets"`
+	// Required fails the assets without a checksum.
+	Required  bool      `yaml:"required"`
+	Signature Signature `yaml:"signature"`
+}
+
+type Signature struct {
+	// Type is one of minisign, cosign and gpg, signature verification is disabled if it's empty.
+	Type string `yaml:"type"`
+	// PublicKey is the path of the local public key file.
+	PublicKey string `yaml:"public_key"`
+	// Required fails the assets without a signature, it's true if it's unset, so that removing the signature asset
+	// never bypasses the verification, see IsRequired. The assets in a signed checksum asset need no signature.
+	Required *bool `yaml:"required"`
+}
+
+// IsRequired reports whether the assets without a signature fail.
+func (s *Signature) IsRequired() bool {
+	return s.Required == nil || *s.Required
 }
 
 type Category struct {
@@ -25,9 +85,32 @@ type Category struct {
 	ParentDir string `yaml:"parent_dir"`
 }
 
+// Mirror rewrites the download urls matching Regex with Replacement, which supports "$1" and "${name}" of the groups.
+type Mirror struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+// Proxy routes the requests to the hosts matching Host through Proxy, or directly if Proxy is ProxyDirect.
+type Proxy struct {
+	Host  string `yaml:"host"`
+	Proxy string `yaml:"proxy"`
+}
+
+type TLS struct {
+	// CaFile is a PEM bundle of the CAs trusted in addition to the system roots.
+	CaFile string `yaml:"ca_file"`
+	// ClientCert and ClientKey are the PEM files of the client certificate.
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+	// InsecureSkipVerify disables certificate verification, it's logged as a warning.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
 type Config struct {
 	ProxyHttp     string `yaml:"proxy_http"`
 	Token         string `yaml:"token"`
+	ApiBase       string `yaml:"api_base"`
 	Timeout       int    `yaml:"timeout"`
 	Retries       int    `yaml:"retries"`
 	TimeFormat    string `yaml:"time_format"`
@@ -35,6 +118,21 @@ type Config struct {
 	LogToFile     bool   `yaml:"log_to_file"`
 	LogDir        string `yaml:"log_dir"`
 	MaxLogFile    int    `yaml:"max_log_file"`
+	Concurrency   int    `yaml:"concurrency"`
+	// MaxRateLimitWait is the max seconds a request waits for rate limits, see SetMaxRateLimitWait.
+	MaxRateLimitWait int `yaml:"max_rate_limit_wait"`
+	// CacheDir is the dir of the API response cache, see GetCached.
+	CacheDir string `yaml:"cache_dir"`
+	// GraphQL lists the releases of GitHub targets with the GraphQL API if a token is configured for them.
+	GraphQL bool `yaml:"graphql"`
+	// PerPage is the page size of listing releases with the GitHub REST API, up to MaxPerPage.
+	PerPage int `yaml:"per_page"`
+	// Mirrors rewrite the download urls of assets, each mirror is tried in turn before the original url.
+	Mirrors []Mirror `yaml:"mirrors"`
+	// Proxies route the hosts through their own proxies, see proxyOf.
+	Proxies []Proxy `yaml:"proxies"`
+	// TLS applies to both API and download requests, see tlsConfigOf.
+	TLS TLS `yaml:"tls"`
 
 	Targets []Target `yaml:"targets"`
 }
@@ -64,6 +162,11 @@ type HistoryAsset struct {
 	UpdatedAt          string `yaml:"updated_at"`
 	ParentDir          string `yaml:"parent_dir"`
 	FileName           string `yaml:"file_name"`
+	Sha256             string `yaml:"sha256,omitempty"`
+	Size               int64  `yaml:"size,omitempty"`
+	DownloadedAt       string `yaml:"downloaded_at,omitempty"`
+	// ServedBy is the host which served the downloaded file, after redirects.
+	ServedBy string `yaml:"served_by,omitempty"`
 }
 
 type HistoryRelease struct {
@@ -77,8 +180,10 @@ type HistoryRelease struct {
 }
 
 type HistoryRepo struct {
-	User     string           `yaml:"user"`
-	Repo     string           `yaml:"repo"`
+	User string `yaml:"user"`
+	Repo string `yaml:"repo"`
+	// Source keeps the synthetic releases of a source apart from the releases of the same repo, see historySource.
+	Source   string           `yaml:"source,omitempty"`
 	Releases []HistoryRelease `yaml:"releases"`
 }
 
@@ -102,6 +207,23 @@ func ReadFromHistory(path string) *History {
 	return &history
 }
 
+// historyRepoIndex returns the index of the repo in history and appends it if absent.
+// The caller must hold historyMutex.
+func historyRepoIndex(user, repo, source string) int {
+	for i, r := range history.Repos {
+		if r.User == user && r.Repo == repo && r.Source == source {
+			return i
+		}
+	}
+	history.Repos = append(history.Repos, HistoryRepo{
+		User:   user,
+		Repo:   repo,
+		Source: source,
+	})
+	return len(history.Repos) - 1
+}
+
+// sortHistory sorts releases of each repo by id, the caller must hold historyMutex.
 func sortHistory() {
 	for i, repo := range history.Repos {
 		releases := repo.Releases
@@ -113,6 +235,9 @@ func sortHistory() {
 }
 
 func SaveHistoryToYaml(name string, history *History) error {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
 	// Sort releases by id.
 	sortHistory()
 
@@ -121,7 +246,7 @@ func SaveHistoryToYaml(name string, history *History) error {
 		return err
 	}
 
-	err = os.WriteFile(name, data, os.ModePerm)
+	err = WriteFileAtomic(name, data, 0644)
 	if err != nil {
 		return err
 	}
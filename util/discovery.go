@@ -0,0 +1,207 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type listedRepo struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Archived bool   `json:"archived"`
+	Fork     bool   `json:"fork"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// isDynamicTarget reports whether the target is expanded into the repos of an owner or the stars of a user.
+func isDynamicTarget(target *Target) bool {
+	return target.Owner != "" || target.Stars != ""
+}
+
+// listedTargets returns the lowercase "user/repo" of the ordinary targets of config.
+func listedTargets(config *Config) map[string]bool {
+	listed := map[string]bool{}
+	for _, target := range config.Targets {
+		if !isDynamicTarget(&target) {
+			if _, err := parseTarget(&target); err == nil {
+				listed[strings.ToLower(target.User+"/"+target.Repo)] = true
+			}
+		}
+	}
+	return listed
+}
+
+// ExpandTargets replaces the owner and stars targets with a target of the template for each repo listed.
+func ExpandTargets(client *http.Client, config *Config) {
+	listed := listedTargets(config)
+
+	var targets []Target
+	for _, target := range config.Targets {
+		if !isDynamicTarget(&target) {
+			targets = append(targets, target)
+			continue
+		}
+
+		expanded, err := expandTarget(client, target, config)
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to list repos of: %s, %v", dynamicTargetName(&target), err)
+			Fprintfln(msg)
+			appendError(dynamicTargetName(&target), "", msg)
+			continue
+		}
+		if len(expanded) != 0 && expanded[0].Token != target.Token {
+			err = registerTargetToken(expanded[0], config)
+			if err != nil {
+				msg := fmt.Sprintf("* err: Refused the targets of: %s, %v", dynamicTargetName(&target), err)
+				Fprintfln(msg)
+				appendError(dynamicTargetName(&target), "", msg)
+				continue
+			}
+		}
+		for _, t := range expanded {
+			key := strings.ToLower(t.User + "/" + t.Repo)
+			if listed[key] {
+				continue
+			}
+			listed[key] = true
+			targets = append(targets, t)
+		}
+		SimplifiedPrintfln("* info: Expanded %s into %d targets.", dynamicTargetName(&target), len(expanded))
+	}
+	config.Targets = targets
+}
+
+func dynamicTargetName(target *Target) string {
+	if target.Owner != "" {
+		return "owner: " + target.Owner
+	}
+	return "stars: " + target.Stars
+}
+
+// dynamicTemplate returns the template of the dynamic target, which inherits provider, api_base and token of the
+// dynamic target if it doesn't set them. The sync mode is SyncLatestRelease if it's empty.
+func dynamicTemplate(owner Target) Target {
+	template := Target{}
+	if owner.Template != nil {
+		template = *owner.Template
+	}
+	template.Url = ""
+	template.Owner = ""
+	template.Stars = ""
+	template.Template = nil
+	if template.Provider == "" {
+		template.Provider = owner.Provider
+	}
+	if template.ApiBase == "" {
+		template.ApiBase = owner.ApiBase
+	}
+	if template.Token == "" {
+		template.Token = owner.Token
+	}
+	if template.Sync == "" {
+		template.Sync = SyncLatestRelease
+	}
+	return template
+}
+
+// expandTarget lists the repos of the owner or the stars of the dynamic target, and creates the targets of the repos
+// matching its filters. The filters match repo names for owner targets, and "user/repo" for stars targets.
+func expandTarget(client *http.Client, owner Target, config *Config) ([]Target, error) {
+	owner.Provider = strings.ToLower(owner.Provider)
+	if owner.Provider == "" {
+		owner.Provider = ProviderGitHub
+	}
+	if owner.Provider != ProviderGitHub {
+		return nil, fmt.Errorf("owner and stars are only supported by provider: %s", ProviderGitHub)
+	}
+	include, err := compileRegexes(owner.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileRegexes(owner.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	apiBase := apiBaseOf(&owner, nil, config)
+	var repos []listedRepo
+	if owner.Owner != "" {
+		repos, err = listRepos(client, fmt.Sprintf("%s/orgs/%s/repos?type=all&per_page=%d", apiBase, url.PathEscape(owner.Owner), MaxPerPage))
+		if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+			// It's a user rather than an organization.
+			repos, err = listRepos(client, fmt.Sprintf("%s/users/%s/repos?type=owner&per_page=%d", apiBase, url.PathEscape(owner.Owner), MaxPerPage))
+		}
+	} else {
+		repos, err = listRepos(client, fmt.Sprintf("%s/users/%s/starred?per_page=%d", apiBase, url.PathEscape(owner.Stars), MaxPerPage))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	template := dynamicTemplate(owner)
+	var targets []Target
+	for _, repo := range repos {
+		if (repo.Archived && !owner.Archived) || (repo.Fork && !owner.Forks) {
+			continue
+		}
+		name := repo.Name
+		if owner.Owner == "" {
+			name = repo.FullName
+		}
+		if len(include) != 0 && !matchAny(include, name) {
+			continue
+		}
+		if matchAny(exclude, name) {
+			continue
+		}
+		target := template
+		target.User = repo.Owner.Login
+		if target.User == "" {
+			target.User = owner.Owner
+		}
+		target.Repo = repo.Name
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// listRepos lists the repos of all pages from api, following the "next" links.
+func listRepos(client *http.Client, api string) ([]listedRepo, error) {
+	var repos []listedRepo
+	for api != "" {
+		var page []listedRepo
+		resp, err := GetJson(client, api, &page)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, page...)
+		api = NextLink(resp.Header.Values("Link"), resp.Request.URL)
+	}
+	return repos, nil
+}
+
+func compileRegexes(exprs []string) ([]*regexp.Regexp, error) {
+	var regexes []*regexp.Regexp
+	for _, expr := range exprs {
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+func matchAny(regexes []*regexp.Regexp, s string) bool {
+	for _, regex := range regexes {
+		if regex.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,12 @@ const (
 	ErrorIO         = 2
 	ErrorDownload   = 3
 	ErrorUnknownCmd = 4
+	ErrorVerify     = 5
+)
+
+const (
+	CommandVerify      = "verify"
+	CommandImportStars = "import-stars"
 )
 
 const (
@@ -23,29 +29,71 @@ const (
 	FileName                      = "${file_name}"
 	CreatedAt                     = "${created_at}"
 	UpdatedAt                     = "${updated_at}"
+	RunNumber                     = "${run_number}"
+	HeadSha                       = "${head_sha}"
+)
+
+const (
+	ProviderGitHub    = "github"
+	ProviderGitea     = "gitea"
+	ProviderForgejo   = "forgejo"
+	ProviderGitLab    = "gitlab"
+	ProviderHttpIndex = "http-index"
+)
+
+const (
+	SourceReleases  = "releases"
+	SourceTags      = "tags"
+	SourceArtifacts = "artifacts"
+)
+
+const (
+	SourceArchiveZip   = "zip"
+	SourceArchiveTarGz = "tar.gz"
+)
+
+const (
+	SignatureMinisign = "minisign"
+	SignatureCosign   = "cosign"
+	SignatureGpg      = "gpg"
 )
 
 type Args struct {
+	Command string
 	Help    bool
 	Version bool
 	DryRun  bool
+	Repair  bool
 	Config  string
 	History string
+	User    string
+	Sync    string
 }
 
 type Release struct {
-	Name        string `json:"name"`
-	TagName     string `json:"tag_name"`
-	Id          int64  `json:"id"`
-	Prerelease  bool   `json:"prerelease"`
-	CreatedAt   string `json:"created_at"`
-	PublishedAt string `json:"published_at"`
-	Assets      []struct {
-		Name               string `json:"name"`
-		BrowserDownloadURL string `json:"browser_download_url"`
-		CreatedAt          string `json:"created_at"`
-		UpdatedAt          string `json:"updated_at"`
-	} `json:"assets"`
+	Name        string  `json:"name"`
+	TagName     string  `json:"tag_name"`
+	Id          int64   `json:"id"`
+	Prerelease  bool    `json:"prerelease"`
+	CreatedAt   string  `json:"created_at"`
+	PublishedAt string  `json:"published_at"`
+	Assets      []Asset `json:"assets"`
+	ZipballUrl  string  `json:"zipball_url"`
+	TarballUrl  string  `json:"tarball_url"`
+	// Vars are the extra vars of file_name and parent_dir, e.g. ${run_number} of workflow runs.
+	Vars map[string]string `json:"-"`
+}
+
+type Asset struct {
+	Id                 int64  `json:"id"`
+	Url                string `json:"url"`
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	CreatedAt          string `json:"created_at"`
+	UpdatedAt          string `json:"updated_at"`
+	Size               int64  `json:"size"`
+	// Source marks the source archives generated by the forge, which aren't covered by checksums or signatures.
+	Source bool `json:"-"`
 }
 
 type Err struct {
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// StatusError means the server responded to a download with an unexpected status code.
+type StatusError struct {
+	Url        string
+	StatusCode int
+	RateLimit  bool
+}
+
+func (e *StatusError) Error() string {
+	if e.RateLimit {
+		return fmt.Sprintf("rate limit exceeded, status code: %d, url: %s", e.StatusCode, e.Url)
+	}
+	return fmt.Sprintf("unexpected status code: %d, url: %s", e.StatusCode, e.Url)
+}
+
+// Retryable reports whether the server may respond successfully next time, e.g. 5xx or rate limit.
+func (e *StatusError) Retryable() bool {
+	switch {
+	case e.RateLimit:
+		return true
+	case e.StatusCode == http.StatusRequestTimeout, e.StatusCode == http.StatusTooEarly, e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode >= http.StatusInternalServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+// LengthError means the bytes written don't match the Content-Length of the response, the transfer was cut off.
+type LengthError struct {
+	Url      string
+	Expected int64
+	Written  int64
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("content length mismatch, expected: %d, written: %d, url: %s", e.Expected, e.Written, e.Url)
+}
+
+// Retryable is always true, the partial file can be resumed.
+func (e *LengthError) Retryable() bool {
+	return true
+}
+
+// SizeError means the downloaded file doesn't match the asset size from the API.
+type SizeError struct {
+	Url      string
+	Expected int64
+	Actual   int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("asset size mismatch, expected: %d, actual: %d, url: %s", e.Expected, e.Actual, e.Url)
+}
+
+// Retryable is always false, the server serves different content than the API describes.
+func (e *SizeError) Retryable() bool {
+	return false
+}
+
+// IsRetryable reports whether another attempt of a failed download may succeed.
+// Errors without a Retryable method, e.g. network errors, are considered retryable.
+func IsRetryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	return true
+}
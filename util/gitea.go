@@ -0,0 +1,100 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// giteaPageSize is the max page size of Gitea by default, the instances may limit it further by MAX_RESPONSE_ITEMS.
+const giteaPageSize = 50
+
+// Gitea lists releases with the Gitea API, which is also served by Forgejo.
+type Gitea struct {
+	client  *http.Client
+	apiBase string
+	pager   *pager
+}
+
+type giteaRelease struct {
+	Release
+	Draft bool `json:"draft"`
+}
+
+func NewGitea(client *http.Client, apiBase string) *Gitea {
+	return &Gitea{client: client, apiBase: apiBase, pager: newPager()}
+}
+
+// toRelease fills the fields Gitea doesn't provide, assets of Gitea have no updated_at.
+func (r *giteaRelease) toRelease() Release {
+	release := r.Release
+	for i := range release.Assets {
+		if release.Assets[i].UpdatedAt == "" {
+			release.Assets[i].UpdatedAt = release.Assets[i].CreatedAt
+		}
+	}
+	return release
+}
+
+// GetRelease skips drafts. The next page is the "next" link, or counted from X-Total-Count without links.
+func (p *Gitea) GetRelease(user, repo string, page int) ([]Release, int) {
+	api := fmt.Sprintf("%s/repos/%s/%s/releases?page=%d&limit=%d", p.apiBase, user, repo, page, giteaPageSize)
+	var giteaReleases []giteaRelease
+	resp, err := GetJson(p.client, p.pager.url(page, api), &giteaReleases)
+	if err != nil {
+		logReleaseError(user, repo, err)
+		return nil, -1
+	}
+
+	var releases []Release
+	for i := range giteaReleases {
+		if !giteaReleases[i].Draft {
+			releases = append(releases, giteaReleases[i].toRelease())
+		}
+	}
+
+	nextPage := -1
+	if len(resp.Header.Values("Link")) != 0 {
+		nextPage = p.pager.nextLink(page, len(giteaReleases), resp)
+	} else if total, err := strconv.Atoi(resp.Header.Get("X-Total-Count")); err == nil {
+		if len(giteaReleases) != 0 && p.pager.offset(page)+len(giteaReleases) < total {
+			nextPage = p.pager.next(page, len(giteaReleases), "")
+		}
+	} else if len(giteaReleases) >= giteaPageSize {
+		nextPage = p.pager.next(page, len(giteaReleases), "")
+	}
+	return releases, nextPage
+}
+
+func (p *Gitea) GetLatestRelease(user, repo string) *Release {
+	api := fmt.Sprintf("%s/repos/%s/%s/releases/latest", p.apiBase, user, repo)
+
+	var giteaRelease giteaRelease
+	_, err := GetJson(p.client, api, &giteaRelease)
+	if err != nil {
+		logReleaseError(user, repo, err)
+		return nil
+	}
+
+	release := giteaRelease.toRelease()
+	return &release
+}
+
+func (p *Gitea) GetReleaseByTag(user, repo, tag string) *Release {
+	api := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", p.apiBase, user, repo, tag)
+
+	var giteaRelease giteaRelease
+	_, err := GetJson(p.client, api, &giteaRelease)
+	if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+		msg := fmt.Sprintf("* err: Tag not found: %s.", tag)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	} else if err != nil {
+		logReleaseError(user, repo, err)
+		return nil
+	}
+
+	release := giteaRelease.toRelease()
+	return &release
+}
@@ -0,0 +1,169 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// giteaFake serves the releases of owner/repo like Gitea, with at most maxItems releases per page like
+// MAX_RESPONSE_ITEMS. The "next" links are sent if links is true, X-Total-Count is always sent.
+func giteaFake(t *testing.T, releases []giteaRelease, maxItems int, links bool) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			http.Error(w, `{"message":"token is required"}`, http.StatusUnauthorized)
+			return
+		}
+		var v interface{}
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/releases":
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			if page < 1 {
+				page = 1
+			}
+			if limit < 1 || limit > maxItems {
+				limit = maxItems
+			}
+			start, end := (page-1)*limit, page*limit
+			if page > 1 && start >= len(releases) {
+				t.Errorf("an empty page is requested: %d", page)
+			}
+			if start > len(releases) {
+				start = len(releases)
+			}
+			if end > len(releases) {
+				end = len(releases)
+			}
+			if links && end < len(releases) {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/api/v1/repos/owner/repo/releases?limit=%d&page=%d>; rel="next"`, server.URL, limit, page+1))
+			}
+			w.Header().Set("X-Total-Count", strconv.Itoa(len(releases)))
+			v = releases[start:end]
+		case "/api/v1/repos/owner/repo/releases/latest":
+			v = releases[1]
+		case "/api/v1/repos/owner/repo/releases/tags/v1.0.0":
+			v = releases[len(releases)-1]
+		default:
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func giteaReleases(n int) []giteaRelease {
+	var releases []giteaRelease
+	for i := n; i > 0; i-- {
+		release := giteaRelease{Release: Release{
+			Id:        int64(i),
+			Name:      fmt.Sprintf("v%d.0.0", i),
+			TagName:   fmt.Sprintf("v%d.0.0", i),
+			CreatedAt: "2024-01-01T00:00:00Z",
+			Assets:    []Asset{{Name: "app.zip", CreatedAt: "2024-01-01T00:00:00Z"}},
+		}}
+		// The latest release is a draft.
+		release.Draft = i == n
+		releases = append(releases, release)
+	}
+	return releases
+}
+
+// newGiteaTest creates the provider of the fake with the token registered like a Gitea target.
+func newGiteaTest(t *testing.T, server *httptest.Server) *Gitea {
+	t.Helper()
+	t.Cleanup(func() {
+		tokens = map[string]string{}
+		Errors = nil
+	})
+	target := Target{Url: server.URL + "/owner/repo", Provider: ProviderGitea, ApiBase: server.URL + "/api/v1", Token: "secret"}
+	if err := registerTargetToken(target, &Config{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewGitea(server.Client(), server.URL+"/api/v1")
+}
+
+func TestGiteaGetRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxItems int
+		links    bool
+	}{
+		{name: "default page size", maxItems: giteaPageSize, links: true},
+		{name: "limited page size with links", maxItems: 3, links: true},
+		{name: "limited page size without links", maxItems: 3, links: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			releases := giteaReleases(8)
+			provider := newGiteaTest(t, giteaFake(t, releases, test.maxItems, test.links))
+
+			var tags []string
+			for page := 1; page != -1; {
+				var got []Release
+				got, page = provider.GetRelease("owner", "repo", page)
+				for _, release := range got {
+					tags = append(tags, release.TagName)
+					if release.Assets[0].UpdatedAt != release.Assets[0].CreatedAt {
+						t.Errorf("updated_at of %s isn't filled", release.TagName)
+					}
+				}
+				if len(tags) > len(releases) {
+					t.Fatalf("paging doesn't stop: %v", tags)
+				}
+			}
+			want := "[v7.0.0 v6.0.0 v5.0.0 v4.0.0 v3.0.0 v2.0.0 v1.0.0]"
+			if fmt.Sprint(tags) != want {
+				t.Fatalf("tags = %v, want %s", tags, want)
+			}
+			if len(Errors) != 0 {
+				t.Fatalf("errors = %v", Errors)
+			}
+		})
+	}
+}
+
+func TestGiteaGetLatestRelease(t *testing.T) {
+	provider := newGiteaTest(t, giteaFake(t, giteaReleases(3), giteaPageSize, true))
+	release := provider.GetLatestRelease("owner", "repo")
+	if release == nil || release.TagName != "v2.0.0" {
+		t.Fatalf("latest release = %+v", release)
+	}
+}
+
+func TestGiteaGetReleaseByTag(t *testing.T) {
+	provider := newGiteaTest(t, giteaFake(t, giteaReleases(3), giteaPageSize, true))
+	release := provider.GetReleaseByTag("owner", "repo", "v1.0.0")
+	if release == nil || release.TagName != "v1.0.0" {
+		t.Fatalf("release = %+v", release)
+	}
+
+	if release := provider.GetReleaseByTag("owner", "repo", "v9.0.0"); release != nil {
+		t.Fatalf("missing tag = %+v", release)
+	}
+	if len(Errors) != 1 || Errors[0].Msg != "* err: Tag not found: v9.0.0." {
+		t.Fatalf("errors = %v", Errors)
+	}
+}
+
+func TestGiteaToken(t *testing.T) {
+	server := giteaFake(t, giteaReleases(3), giteaPageSize, true)
+	provider := NewGitea(server.Client(), server.URL+"/api/v1")
+	t.Cleanup(func() { Errors = nil })
+	if release := provider.GetLatestRelease("owner", "repo"); release != nil {
+		t.Fatal("the fake accepts requests without the token")
+	}
+
+	provider = newGiteaTest(t, server)
+	if release := provider.GetLatestRelease("owner", "repo"); release == nil {
+		t.Fatalf("errors = %v", Errors)
+	}
+}
@@ -0,0 +1,132 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GitHubApi is the REST API base of github.com.
+const GitHubApi = "https://api.github.com"
+
+// MaxPerPage is the max page size of the GitHub REST API.
+const MaxPerPage = 100
+
+// GitHub lists releases with the GitHub REST API of github.com or a GitHub Enterprise Server.
+type GitHub struct {
+	client  *http.Client
+	apiBase string
+	perPage int
+	pager   *pager
+}
+
+// NewGitHub creates a GitHub provider, perPage is clamped to MaxPerPage and the default page size of the API is used
+// if it's not greater than 0.
+func NewGitHub(client *http.Client, apiBase string, perPage int) *GitHub {
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+	return &GitHub{client: client, apiBase: apiBase, perPage: perPage, pager: newPager()}
+}
+
+func (p *GitHub) GetRelease(user, repo string, page int) ([]Release, int) {
+	api := fmt.Sprintf("%s/repos/%s/%s/releases?page=%d", p.apiBase, user, repo, page)
+	if p.perPage > 0 {
+		api = fmt.Sprintf("%s&per_page=%d", api, p.perPage)
+	}
+	api = p.pager.url(page, api)
+
+	resp, err := GetCached(p.client, api)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to get: %s, %v", api, err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("* err: Failed to access, status code: %d.", resp.StatusCode)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+
+	var releases []Release
+	err = json.NewDecoder(resp.Body).Decode(&releases)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to parse release body: %v", err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+
+	return releases, p.pager.nextLink(page, len(releases), resp)
+}
+
+func (p *GitHub) GetLatestRelease(user, repo string) *Release {
+	api := fmt.Sprintf("%s/repos/%s/%s/releases/latest", p.apiBase, user, repo)
+
+	resp, err := GetCached(p.client, api)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to get: %s, %v", api, err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("* err: Failed to access, status code: %d.", resp.StatusCode)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+
+	var release Release
+	err = json.NewDecoder(resp.Body).Decode(&release)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to parse release body: %v", err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+
+	return &release
+}
+
+func (p *GitHub) GetReleaseByTag(user, repo, tag string) *Release {
+	api := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", p.apiBase, user, repo, tag)
+
+	resp, err := GetCached(p.client, api)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to get: %s, %v", api, err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		msg := fmt.Sprintf("* err: Tag not found: %s.", tag)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	} else if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("* err: Failed to access, status code: %d.", resp.StatusCode)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+
+	var release Release
+	err = json.NewDecoder(resp.Body).Decode(&release)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to parse release body: %v", err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+
+	return &release
+}
@@ -0,0 +1,144 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// gitlabPageSize is the max page size of GitLab.
+const gitlabPageSize = 100
+
+// GitLab lists releases with the GitLab REST API.
+type GitLab struct {
+	client  *http.Client
+	apiBase string
+}
+
+type gitlabRelease struct {
+	Name            string `json:"name"`
+	TagName         string `json:"tag_name"`
+	CreatedAt       string `json:"created_at"`
+	ReleasedAt      string `json:"released_at"`
+	UpcomingRelease bool   `json:"upcoming_release"`
+	Assets          struct {
+		Sources []struct {
+			Format string `json:"format"`
+			Url    string `json:"url"`
+		} `json:"sources"`
+		Links []struct {
+			Name           string `json:"name"`
+			Url            string `json:"url"`
+			DirectAssetUrl string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+func NewGitLab(client *http.Client, apiBase string) *GitLab {
+	return &GitLab{client: client, apiBase: apiBase}
+}
+
+// toRelease maps links to assets, and upcoming releases to prereleases. The id is the datedId of released_at.
+func (r *gitlabRelease) toRelease() Release {
+	release := Release{
+		Name:        r.Name,
+		TagName:     r.TagName,
+		Prerelease:  r.UpcomingRelease,
+		CreatedAt:   r.CreatedAt,
+		PublishedAt: r.ReleasedAt,
+	}
+	releasedAt, err := time.Parse(time.RFC3339, r.ReleasedAt)
+	if err != nil {
+		releasedAt, err = time.Parse(time.RFC3339, r.CreatedAt)
+	}
+	if err != nil {
+		// It's still distinguished by the tag name, but ordered as older than all dated releases.
+		Fprintfln("* err: Invalid released_at: \"%s\" and created_at: \"%s\" of %s.", r.ReleasedAt, r.CreatedAt, r.TagName)
+		releasedAt = time.Unix(0, 0)
+	}
+	release.Id = datedId(releasedAt, r.TagName)
+
+	for _, link := range r.Assets.Links {
+		downloadUrl := link.DirectAssetUrl
+		if downloadUrl == "" {
+			downloadUrl = link.Url
+		}
+		release.Assets = append(release.Assets, Asset{
+			Name:               link.Name,
+			BrowserDownloadURL: downloadUrl,
+			CreatedAt:          r.CreatedAt,
+			UpdatedAt:          r.CreatedAt,
+		})
+	}
+	for _, source := range r.Assets.Sources {
+		switch source.Format {
+		case SourceArchiveZip:
+			release.ZipballUrl = source.Url
+		case SourceArchiveTarGz:
+			release.TarballUrl = source.Url
+		}
+	}
+	return release
+}
+
+// project returns the url encoded path of the project, e.g. "group%2Fsubgroup%2Fproject".
+func (p *GitLab) project(user, repo string) string {
+	return url.PathEscape(user + "/" + repo)
+}
+
+func (p *GitLab) GetRelease(user, repo string, page int) ([]Release, int) {
+	api := fmt.Sprintf("%s/projects/%s/releases?page=%d&per_page=%d", p.apiBase, p.project(user, repo), page, gitlabPageSize)
+
+	var gitlabReleases []gitlabRelease
+	resp, err := GetJson(p.client, api, &gitlabReleases)
+	if err != nil {
+		logReleaseError(user, repo, err)
+		return nil, -1
+	}
+
+	var releases []Release
+	for i := range gitlabReleases {
+		releases = append(releases, gitlabReleases[i].toRelease())
+	}
+
+	nextPage, err := strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	if err != nil {
+		nextPage = -1
+	}
+	return releases, nextPage
+}
+
+func (p *GitLab) GetLatestRelease(user, repo string) *Release {
+	api := fmt.Sprintf("%s/projects/%s/releases/permalink/latest", p.apiBase, p.project(user, repo))
+
+	var gitlabRelease gitlabRelease
+	_, err := GetJson(p.client, api, &gitlabRelease)
+	if err != nil {
+		logReleaseError(user, repo, err)
+		return nil
+	}
+
+	release := gitlabRelease.toRelease()
+	return &release
+}
+
+func (p *GitLab) GetReleaseByTag(user, repo, tag string) *Release {
+	api := fmt.Sprintf("%s/projects/%s/releases/%s", p.apiBase, p.project(user, repo), url.PathEscape(tag))
+
+	var gitlabRelease gitlabRelease
+	_, err := GetJson(p.client, api, &gitlabRelease)
+	if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+		msg := fmt.Sprintf("* err: Tag not found: %s.", tag)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	} else if err != nil {
+		logReleaseError(user, repo, err)
+		return nil
+	}
+
+	release := gitlabRelease.toRelease()
+	return &release
+}
@@ -0,0 +1,368 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// graphqlBatchSize is the max repos listed by one query in PrefetchGraphQL.
+const graphqlBatchSize = 10
+
+// The fragments of the queries, 100 is the max page size of the GitHub GraphQL API. GraphQL rejects a query with any
+// unused fragment, so each query only has the fragments it spreads.
+const (
+	graphqlAssetsFragment = `
+fragment assets on ReleaseAssetConnection {
+  pageInfo { hasNextPage endCursor }
+  nodes { databaseId name downloadUrl createdAt updatedAt size }
+}`
+	graphqlReleaseFragment = `
+fragment release on Release {
+  id databaseId name tagName isPrerelease createdAt publishedAt
+  releaseAssets(first: 100) { ...assets }
+}` + graphqlAssetsFragment
+	graphqlReleasesFragment = `
+fragment releases on Repository {
+  releases(first: 100, after: $cursor, orderBy: {field: CREATED_AT, direction: DESC}) {
+    pageInfo { hasNextPage endCursor }
+    nodes { ...release }
+  }
+}` + graphqlReleaseFragment
+)
+
+type graphqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphqlAssets struct {
+	PageInfo graphqlPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		DatabaseId  int64  `json:"databaseId"`
+		Name        string `json:"name"`
+		DownloadUrl string `json:"downloadUrl"`
+		CreatedAt   string `json:"createdAt"`
+		UpdatedAt   string `json:"updatedAt"`
+		Size        int64  `json:"size"`
+	} `json:"nodes"`
+}
+
+type graphqlRelease struct {
+	Id            string        `json:"id"`
+	DatabaseId    int64         `json:"databaseId"`
+	Name          string        `json:"name"`
+	TagName       string        `json:"tagName"`
+	IsPrerelease  bool          `json:"isPrerelease"`
+	CreatedAt     string        `json:"createdAt"`
+	PublishedAt   string        `json:"publishedAt"`
+	ReleaseAssets graphqlAssets `json:"releaseAssets"`
+}
+
+type graphqlReleases struct {
+	PageInfo graphqlPageInfo  `json:"pageInfo"`
+	Nodes    []graphqlRelease `json:"nodes"`
+}
+
+type graphqlRepository struct {
+	Releases      *graphqlReleases `json:"releases"`
+	LatestRelease *graphqlRelease  `json:"latestRelease"`
+	Release       *graphqlRelease  `json:"release"`
+	Refs          *graphqlTagRefs  `json:"refs"`
+	Ref           *graphqlTagRef   `json:"ref"`
+}
+
+type graphqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+var (
+	// graphqlPrefetched maps "apiBase/user/repo" to the first page of releases listed by PrefetchGraphQL.
+	graphqlPrefetched      = map[string]*graphqlReleases{}
+	graphqlPrefetchedMutex sync.RWMutex
+)
+
+// GitHubGraphQL lists releases with the GitHub GraphQL API, which requires a token. A page has 100 releases with
+// their assets, instead of 30 releases of the REST API.
+type GitHubGraphQL struct {
+	client   *http.Client
+	apiBase  string
+	endpoint string
+	// cursors maps pages to the cursors of the releases connection.
+	cursors      map[int]string
+	cursorsMutex sync.Mutex
+}
+
+func NewGitHubGraphQL(client *http.Client, apiBase string) *GitHubGraphQL {
+	return &GitHubGraphQL{client: client, apiBase: apiBase, endpoint: graphqlEndpointOf(apiBase), cursors: map[int]string{}}
+}
+
+// graphqlEndpointOf returns the GraphQL endpoint of the REST API base, e.g. "https://ghe.corp/api/graphql" for
+// "https://ghe.corp/api/v3".
+func graphqlEndpointOf(apiBase string) string {
+	if strings.HasSuffix(apiBase, "/api/v3") {
+		return strings.TrimSuffix(apiBase, "/v3") + "/graphql"
+	}
+	return apiBase + "/graphql"
+}
+
+// useGraphQL reports whether the GraphQL API is used for the GitHub target, it's only available with a token.
+func useGraphQL(target *Target, apiBase string, config *Config) bool {
+	if !config.GraphQL || target.Provider != ProviderGitHub || (target.Source != "" && target.Source != SourceReleases) {
+		return false
+	}
+	endpoint, err := url.Parse(graphqlEndpointOf(apiBase))
+	return err == nil && HasToken(endpoint.Host)
+}
+
+// listsReleases reports whether the sync mode lists the releases, instead of getting the latest one or a tag.
+func listsReleases(sync string) bool {
+	switch sync {
+	case SyncLatest, SyncLatestReleases, SyncLatestPrerelease, SyncFromLatestLocal, SyncReleaseFromLatestLocal,
+		SyncPrereleaseFromLatestLocal, SyncAll:
+		return true
+	default:
+		return false
+	}
+}
+
+// query posts the query with the variables, and returns the data or an error of the request or the GraphQL errors.
+func (p *GitHubGraphQL) query(query string, variables map[string]interface{}) (map[string]json.RawMessage, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Post(p.client, p.endpoint, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	var result graphqlResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Errors) != 0 {
+		var messages []string
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return result.Data, fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	return result.Data, nil
+}
+
+// repository queries the repository of the user and repo with the selection and its fragments.
+func (p *GitHubGraphQL) repository(user, repo, variableDefinitions, selection, fragments string, variables map[string]interface{}) *graphqlRepository {
+	variables["owner"] = user
+	variables["name"] = repo
+	query := fmt.Sprintf("query($owner: String!, $name: String!%s) { repository(owner: $owner, name: $name) { %s } }%s", variableDefinitions, selection, fragments)
+	data, err := p.query(query, variables)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to query: %s, %v", p.endpoint, err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+
+	var repository *graphqlRepository
+	err = json.Unmarshal(data["repository"], &repository)
+	if err != nil || repository == nil {
+		msg := fmt.Sprintf("* err: Failed to parse release body: %v", err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	return repository
+}
+
+// toRelease maps the release onto Release, the rest of its assets are queried if there are more than 100.
+func (p *GitHubGraphQL) toRelease(user, repo string, r *graphqlRelease) *Release {
+	release := &Release{
+		Name:        r.Name,
+		TagName:     r.TagName,
+		Id:          r.DatabaseId,
+		Prerelease:  r.IsPrerelease,
+		CreatedAt:   r.CreatedAt,
+		PublishedAt: r.PublishedAt,
+		ZipballUrl:  fmt.Sprintf("%s/repos/%s/%s/zipball/%s", p.apiBase, user, repo, url.PathEscape(r.TagName)),
+		TarballUrl:  fmt.Sprintf("%s/repos/%s/%s/tarball/%s", p.apiBase, user, repo, url.PathEscape(r.TagName)),
+	}
+	assets := r.ReleaseAssets
+	for {
+		for _, a := range assets.Nodes {
+			release.Assets = append(release.Assets, Asset{
+				Id:                 a.DatabaseId,
+				Url:                fmt.Sprintf("%s/repos/%s/%s/releases/assets/%d", p.apiBase, user, repo, a.DatabaseId),
+				Name:               a.Name,
+				BrowserDownloadURL: a.DownloadUrl,
+				CreatedAt:          a.CreatedAt,
+				UpdatedAt:          a.UpdatedAt,
+				Size:               a.Size,
+			})
+		}
+		if !assets.PageInfo.HasNextPage {
+			return release
+		}
+
+		query := "query($id: ID!, $cursor: String) { node(id: $id) { ... on Release { releaseAssets(first: 100, after: $cursor) { ...assets } } } }" + graphqlAssetsFragment
+		data, err := p.query(query, map[string]interface{}{"id": r.Id, "cursor": assets.PageInfo.EndCursor})
+		var node struct {
+			ReleaseAssets graphqlAssets `json:"releaseAssets"`
+		}
+		if err == nil {
+			err = json.Unmarshal(data["node"], &node)
+		}
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to query assets of release: %s, %v", r.TagName, err)
+			Fprintfln(msg)
+			appendError(user, repo, msg)
+			return release
+		}
+		assets = node.ReleaseAssets
+	}
+}
+
+func (p *GitHubGraphQL) GetRelease(user, repo string, page int) ([]Release, int) {
+	p.cursorsMutex.Lock()
+	cursor, ok := p.cursors[page]
+	p.cursorsMutex.Unlock()
+	if page != 1 && !ok {
+		msg := fmt.Sprintf("* err: Unknown page: %d.", page)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+
+	var connection *graphqlReleases = nil
+	if page == 1 {
+		graphqlPrefetchedMutex.RLock()
+		connection = graphqlPrefetched[fmt.Sprintf("%s/%s/%s", p.apiBase, user, repo)]
+		graphqlPrefetchedMutex.RUnlock()
+	}
+	if connection == nil {
+		variables := map[string]interface{}{}
+		if cursor != "" {
+			variables["cursor"] = cursor
+		}
+		repository := p.repository(user, repo, ", $cursor: String", "...releases", graphqlReleasesFragment, variables)
+		if repository == nil || repository.Releases == nil {
+			return nil, -1
+		}
+		connection = repository.Releases
+	}
+
+	var releases []Release
+	for i := range connection.Nodes {
+		releases = append(releases, *p.toRelease(user, repo, &connection.Nodes[i]))
+	}
+	nextPage := -1
+	if connection.PageInfo.HasNextPage {
+		nextPage = page + 1
+		p.cursorsMutex.Lock()
+		p.cursors[nextPage] = connection.PageInfo.EndCursor
+		p.cursorsMutex.Unlock()
+	}
+	return releases, nextPage
+}
+
+func (p *GitHubGraphQL) GetLatestRelease(user, repo string) *Release {
+	repository := p.repository(user, repo, "", "latestRelease { ...release }", graphqlReleaseFragment, map[string]interface{}{})
+	if repository == nil {
+		return nil
+	}
+	if repository.LatestRelease == nil {
+		msg := "* err: No latest release."
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	return p.toRelease(user, repo, repository.LatestRelease)
+}
+
+func (p *GitHubGraphQL) GetReleaseByTag(user, repo, tag string) *Release {
+	repository := p.repository(user, repo, ", $tag: String!", "release(tagName: $tag) { ...release }", graphqlReleaseFragment, map[string]interface{}{"tag": tag})
+	if repository == nil {
+		return nil
+	}
+	if repository.Release == nil {
+		msg := fmt.Sprintf("* err: Tag not found: %s.", tag)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	return p.toRelease(user, repo, repository.Release)
+}
+
+// PrefetchGraphQL lists the first page of releases of graphqlBatchSize GitHub targets a query.
+func PrefetchGraphQL(client *http.Client, config *Config) {
+	if !config.GraphQL {
+		return
+	}
+
+	// Group the repos by API base.
+	var apiBases []string
+	repos := map[string][][2]string{}
+	seen := map[string]bool{}
+	for _, target := range config.Targets {
+		targetUrl, err := parseTarget(&target)
+		if err != nil || !listsReleases(target.Sync) {
+			continue
+		}
+		apiBase := apiBaseOf(&target, targetUrl, config)
+		key := fmt.Sprintf("%s/%s/%s", apiBase, target.User, target.Repo)
+		if !useGraphQL(&target, apiBase, config) || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := repos[apiBase]; !ok {
+			apiBases = append(apiBases, apiBase)
+		}
+		repos[apiBase] = append(repos[apiBase], [2]string{target.User, target.Repo})
+	}
+
+	for _, apiBase := range apiBases {
+		p := NewGitHubGraphQL(client, apiBase)
+		for start := 0; start < len(repos[apiBase]); start += graphqlBatchSize {
+			end := start + graphqlBatchSize
+			if end > len(repos[apiBase]) {
+				end = len(repos[apiBase])
+			}
+			batch := repos[apiBase][start:end]
+
+			var definitions, selections []string
+			variables := map[string]interface{}{}
+			for i, r := range batch {
+				definitions = append(definitions, fmt.Sprintf("$owner%d: String!, $name%d: String!", i, i))
+				selections = append(selections, fmt.Sprintf("r%d: repository(owner: $owner%d, name: $name%d) { ...releases }", i, i, i))
+				variables[fmt.Sprintf("owner%d", i)] = r[0]
+				variables[fmt.Sprintf("name%d", i)] = r[1]
+			}
+			query := fmt.Sprintf("query(%s, $cursor: String) { %s }%s", strings.Join(definitions, ", "), strings.Join(selections, " "), graphqlReleasesFragment)
+			data, err := p.query(query, variables)
+			if err != nil {
+				// Partial data is still returned with the errors of some repos, e.g. a missing repo.
+				SimplifiedPrintfln("* info: Failed to prefetch releases from: %s, %v", p.endpoint, err)
+			}
+
+			graphqlPrefetchedMutex.Lock()
+			for i, r := range batch {
+				var repository *graphqlRepository
+				if json.Unmarshal(data[fmt.Sprintf("r%d", i)], &repository) == nil && repository != nil && repository.Releases != nil {
+					graphqlPrefetched[fmt.Sprintf("%s/%s/%s", apiBase, r[0], r[1])] = repository.Releases
+				}
+			}
+			graphqlPrefetchedMutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	indexLinkRegex = regexp.MustCompile(`(?is)^<a\s[^>]*?href\s*=\s*["']([^"']*)["'][^>]*>.*?</a>(.*)$`)
+	indexTagRegex  = regexp.MustCompile(`(?s)<[^>]*>`)
+	// indexDateRegexes match the dates of nginx, e.g. "01-Jan-2024 10:00", and Apache, e.g. "2024-01-01 10:00".
+	indexDateRegexes = map[string]*regexp.Regexp{
+		"02-Jan-2006 15:04": regexp.MustCompile(`\d{2}-[A-Za-z]{3}-\d{4} \d{2}:\d{2}`),
+		"2006-01-02 15:04":  regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}`),
+	}
+	versionNumberRegex = regexp.MustCompile(`\d+`)
+)
+
+// indexFile is a file listed by an index.
+type indexFile struct {
+	Name    string
+	Url     string
+	Version string
+	Size    int64
+	Date    string
+}
+
+// HttpIndex lists the files of an autoindex HTML page of nginx or Apache, or a JSON manifest, and groups them into
+// synthetic releases by the version matched by the version regex.
+type HttpIndex struct {
+	client       *http.Client
+	indexUrl     string
+	versionRegex *regexp.Regexp
+}
+
+func NewHttpIndex(client *http.Client, indexUrl string, versionRegex *regexp.Regexp) *HttpIndex {
+	return &HttpIndex{client: client, indexUrl: indexUrl, versionRegex: versionRegex}
+}
+
+// listFiles gets the index and parses it as a JSON manifest if it's JSON, or as an HTML page otherwise.
+func (p *HttpIndex) listFiles() ([]indexFile, error) {
+	resp, err := GetCached(p.client, p.indexUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Url: p.indexUrl, StatusCode: resp.StatusCode}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	base := resp.Request.URL
+
+	trimmed := bytes.TrimSpace(body)
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") || bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		return parseJsonIndex(trimmed, base)
+	}
+	return parseHtmlIndex(string(body), base), nil
+}
+
+// parseHtmlIndex parses the links of an autoindex page, the date and size are parsed from the text after each link if
+// they're present. Links to queries, fragments, dirs and other hosts are skipped.
+func parseHtmlIndex(body string, base *url.URL) []indexFile {
+	var files []indexFile
+	chunks := strings.Split(body, "<a ")
+	for _, chunk := range chunks[1:] {
+		matches := indexLinkRegex.FindStringSubmatch("<a " + chunk)
+		if len(matches) != 3 {
+			continue
+		}
+		href := strings.TrimSpace(matches[1])
+		if href == "" || strings.HasPrefix(href, "?") || strings.HasPrefix(href, "#") || strings.HasSuffix(href, "/") {
+			continue
+		}
+		link, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		link = base.ResolveReference(link)
+		if !strings.EqualFold(link.Host, base.Host) || strings.HasSuffix(link.Path, "/") {
+			continue
+		}
+
+		file := indexFile{Name: path.Base(link.Path), Url: link.String()}
+		text := strings.Join(strings.Fields(indexTagRegex.ReplaceAllString(matches[2], " ")), " ")
+		for layout, regex := range indexDateRegexes {
+			if date := regex.FindString(text); date != "" {
+				if t, err := time.Parse(layout, date); err == nil {
+					file.Date = t.UTC().Format(time.RFC3339)
+					text = strings.Replace(text, date, "", 1)
+				}
+				break
+			}
+		}
+		if fields := strings.Fields(text); len(fields) != 0 {
+			// Only exact sizes are used, e.g. "1048576" rather than "1.0M".
+			if size, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				file.Size = size
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// parseJsonIndex parses a JSON manifest, a list of urls or file objects, or an object with the list in "files".
+func parseJsonIndex(body []byte, base *url.URL) ([]indexFile, error) {
+	var manifest interface{}
+	err := json.Unmarshal(body, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	if object, ok := manifest.(map[string]interface{}); ok {
+		manifest = object["files"]
+		if manifest == nil {
+			manifest = object["assets"]
+		}
+	}
+	list, ok := manifest.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no list of files in the manifest")
+	}
+
+	stringOf := func(object map[string]interface{}, keys ...string) string {
+		for _, key := range keys {
+			if value, ok := object[key].(string); ok && value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+	var files []indexFile
+	for _, item := range list {
+		var file indexFile
+		switch v := item.(type) {
+		case string:
+			file.Url = v
+		case map[string]interface{}:
+			file.Url = stringOf(v, "url", "href", "path")
+			file.Name = stringOf(v, "name")
+			file.Version = stringOf(v, "version")
+			file.Date = stringOf(v, "date", "updated_at", "created_at")
+			if size, ok := v["size"].(float64); ok {
+				file.Size = int64(size)
+			}
+		}
+		link, err := url.Parse(file.Url)
+		if file.Url == "" || err != nil {
+			continue
+		}
+		file.Url = base.ResolveReference(link).String()
+		if file.Name == "" {
+			file.Name = path.Base(link.Path)
+		}
+		if _, err := time.Parse(time.RFC3339, file.Date); err != nil {
+			file.Date = ""
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// matchVersion returns the "version" group of the version regex, or the first group, or the whole match.
+func (p *HttpIndex) matchVersion(name string) string {
+	if p.versionRegex == nil {
+		return ""
+	}
+	matches := p.versionRegex.FindStringSubmatch(name)
+	if matches == nil {
+		return ""
+	}
+	if index := p.versionRegex.SubexpIndex("version"); index != -1 {
+		return matches[index]
+	}
+	if len(matches) > 1 {
+		return matches[1]
+	}
+	return matches[0]
+}
+
+// versionId returns an id which keeps the order of versions, with a hash of the raw version in the low 8 bits.
+func versionId(version string) (int64, bool) {
+	raw := version
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	core := version
+	suffix := ""
+	if index := strings.IndexFunc(version, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	}); index != -1 {
+		core = version[:index]
+		suffix = version[index:]
+	}
+
+	var numbers []int64
+	for _, s := range versionNumberRegex.FindAllString(core, -1) {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		numbers = append(numbers, n)
+	}
+	var id int64 = 0
+	if len(numbers) == 1 {
+		id = numbers[0]
+		if id >= 1<<48 {
+			id = 1<<48 - 1
+		}
+	} else {
+		for i := 0; i < 4; i++ {
+			var n int64 = 0
+			if i < len(numbers) {
+				n = numbers[i]
+			}
+			if n >= 1<<12 {
+				n = 1<<12 - 1
+			}
+			id = id<<12 | n
+		}
+	}
+
+	prerelease := strings.Trim(suffix, "-_.+") != ""
+	var rank int64 = 127
+	if prerelease {
+		rank = 0
+		if s := versionNumberRegex.FindString(suffix); s != "" {
+			rank, _ = strconv.ParseInt(s, 10, 64)
+			if rank > 126 {
+				rank = 126
+			}
+		}
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(raw))
+	return (id<<7|rank)<<8 | int64(hash.Sum32()%256), prerelease
+}
+
+// GetRelease returns all versions sorted by versionId on the first page, the files which don't match the version
+// regex are skipped. The time of a release is the latest time of its files.
+func (p *HttpIndex) GetRelease(user, repo string, page int) ([]Release, int) {
+	if page != 1 {
+		return nil, -1
+	}
+	files, err := p.listFiles()
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to list index: %s, %v", p.indexUrl, err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+
+	releases := map[string]*Release{}
+	var versions []string
+	for _, file := range files {
+		version := file.Version
+		if version == "" {
+			version = p.matchVersion(file.Name)
+		}
+		if version == "" {
+			continue
+		}
+		release, ok := releases[version]
+		if !ok {
+			id, prerelease := versionId(version)
+			release = &Release{Name: version, TagName: version, Id: id, Prerelease: prerelease}
+			releases[version] = release
+			versions = append(versions, version)
+		}
+		date := file.Date
+		if date == "" {
+			// The files without a date are never regarded as updated.
+			date = time.Unix(0, 0).UTC().Format(time.RFC3339)
+		}
+		if date > release.CreatedAt {
+			release.CreatedAt = date
+			release.PublishedAt = date
+		}
+		release.Assets = append(release.Assets, Asset{
+			Name:               file.Name,
+			BrowserDownloadURL: file.Url,
+			CreatedAt:          date,
+			UpdatedAt:          date,
+			Size:               file.Size,
+		})
+	}
+
+	var result []Release
+	for _, version := range versions {
+		result = append(result, *releases[version])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Id > result[j].Id
+	})
+	return result, -1
+}
+
+// GetLatestRelease returns the latest version which isn't a pre-release.
+func (p *HttpIndex) GetLatestRelease(user, repo string) *Release {
+	releases, _ := p.GetRelease(user, repo, 1)
+	for i := range releases {
+		if !releases[i].Prerelease {
+			return &releases[i]
+		}
+	}
+	msg := "* err: No release versions in the index."
+	Fprintfln(msg)
+	appendError(user, repo, msg)
+	return nil
+}
+
+func (p *HttpIndex) GetReleaseByTag(user, repo, tag string) *Release {
+	releases, _ := p.GetRelease(user, repo, 1)
+	for i := range releases {
+		if releases[i].TagName == tag {
+			return &releases[i]
+		}
+	}
+	msg := fmt.Sprintf("* err: Version not found: %s.", tag)
+	Fprintfln(msg)
+	appendError(user, repo, msg)
+	return nil
+}
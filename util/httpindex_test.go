@@ -0,0 +1,24 @@
+package util
+
+import "testing"
+
+func TestVersionId(t *testing.T) {
+	// From the newest to the oldest.
+	versions := []string{"2.0", "2.0-beta", "1.10", "1.9.1", "1.0.0-rc2", "1.0.0-rc1", "0.9"}
+	for i := 1; i < len(versions); i++ {
+		newer, _ := versionId(versions[i-1])
+		older, _ := versionId(versions[i])
+		if newer <= older {
+			t.Errorf("versionId(%s) = %d, not greater than versionId(%s) = %d", versions[i-1], newer, versions[i], older)
+		}
+	}
+
+	seen := map[int64]string{}
+	for _, version := range []string{"1.0", "1.0.0", "v1.0.0", "1.0.0.0"} {
+		id, _ := versionId(version)
+		if other, ok := seen[id]; ok {
+			t.Errorf("%s and %s have the same id: %d", version, other, id)
+		}
+		seen[id] = version
+	}
+}
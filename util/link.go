@@ -0,0 +1,122 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Link is a link of the Link header, see RFC 8288.
+type Link struct {
+	Url string
+	// Params maps the lowercase names of the params to their unquoted values.
+	Params map[string]string
+}
+
+// HasRel reports whether the rel param of the link has the relation type, which is a space-separated list.
+func (l *Link) HasRel(rel string) bool {
+	for _, r := range strings.Fields(l.Params["rel"]) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLinkHeader parses the values of the Link header, malformed links are skipped.
+func ParseLinkHeader(values []string) []Link {
+	var links []Link
+	for _, value := range values {
+		for {
+			value = strings.TrimLeft(value, " \t,")
+			if value == "" {
+				break
+			}
+			if value[0] != '<' {
+				// Skip the malformed link.
+				_, value = splitUnquoted(value, ',')
+				continue
+			}
+			end := strings.IndexByte(value, '>')
+			if end == -1 {
+				break
+			}
+			if next := strings.IndexByte(value[1:end], '<'); next != -1 {
+				// The link misses '>', since '<' isn't allowed in urls. Skip it to the next one.
+				value = value[1+next:]
+				continue
+			}
+			link := Link{Url: strings.TrimSpace(value[1:end]), Params: map[string]string{}}
+			value = value[end+1:]
+
+			var params string
+			params, value = splitUnquoted(value, ',')
+			for params != "" {
+				var param string
+				param, params = splitUnquoted(params, ';')
+				name, val, _ := strings.Cut(param, "=")
+				name = strings.ToLower(strings.TrimSpace(name))
+				if name == "" {
+					continue
+				}
+				if _, ok := link.Params[name]; ok {
+					// Only the first occurrence of a param is used.
+					continue
+				}
+				link.Params[name] = unquote(strings.TrimSpace(val))
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// NextLink returns the url of the "next" link in the Link header, resolved against the url of the response, or ""
+// if there's no next link.
+func NextLink(values []string, base *url.URL) string {
+	for _, link := range ParseLinkHeader(values) {
+		if !link.HasRel("next") {
+			continue
+		}
+		next, err := url.Parse(link.Url)
+		if err != nil {
+			continue
+		}
+		if base != nil {
+			next = base.ResolveReference(next)
+		}
+		return next.String()
+	}
+	return ""
+}
+
+// splitUnquoted splits s at the first sep outside of a quoted string.
+func splitUnquoted(s string, sep byte) (string, string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// unquote removes the quotes and escapes of a quoted string, other values are returned as is.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
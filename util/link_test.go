@@ -0,0 +1,116 @@
+package util
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestNextLink(t *testing.T) {
+	base, _ := url.Parse("https://api.github.com/repositories/1/releases?per_page=100")
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{
+			name: "github",
+			values: []string{`<https://api.github.com/repositories/1/releases?per_page=100&page=2>; rel="next", ` +
+				`<https://api.github.com/repositories/1/releases?per_page=100&page=5>; rel="last"`},
+			want: "https://api.github.com/repositories/1/releases?per_page=100&page=2",
+		},
+		{
+			name:   "extra query params",
+			values: []string{`<https://api.github.com/r?page=2&per_page=100&after=Y3Vyc29y%3D&sort=desc>; rel="next"`},
+			want:   "https://api.github.com/r?page=2&per_page=100&after=Y3Vyc29y%3D&sort=desc",
+		},
+		{
+			name:   "reordered params",
+			values: []string{`<https://x.test/1>; rel="prev"; title="first", <https://x.test/3>; title="third"; type="application/json"; rel="next"`},
+			want:   "https://x.test/3",
+		},
+		{
+			name:   "unquoted rel",
+			values: []string{`<https://x.test/2>; rel=next`},
+			want:   "https://x.test/2",
+		},
+		{
+			name:   "case insensitive rel",
+			values: []string{`<https://x.test/2>; REL="Next"`},
+			want:   "https://x.test/2",
+		},
+		{
+			name:   "multi-value rel",
+			values: []string{`<https://x.test/2>; rel="prev next"`},
+			want:   "https://x.test/2",
+		},
+		{
+			name:   "rel with the prefix of next",
+			values: []string{`<https://x.test/2>; rel="nextpage"`},
+			want:   "",
+		},
+		{
+			name:   "comma and semicolon in quoted params",
+			values: []string{`<https://x.test/1>; title="a, b; rel=\"next\""; rel="prev", <https://x.test/3>; rel="next"`},
+			want:   "https://x.test/3",
+		},
+		{
+			name:   "comma and semicolon in url",
+			values: []string{`<https://x.test/r;v=1?ids=1,2,3&page=2>; rel="next"`},
+			want:   "https://x.test/r;v=1?ids=1,2,3&page=2",
+		},
+		{
+			name:   "relative url",
+			values: []string{`</repositories/1/releases?per_page=100&page=3>; rel="next"`},
+			want:   "https://api.github.com/repositories/1/releases?per_page=100&page=3",
+		},
+		{
+			name:   "multiple header values",
+			values: []string{`<https://x.test/1>; rel="prev"`, `<https://x.test/3>; rel="next"`},
+			want:   "https://x.test/3",
+		},
+		{
+			name:   "missing >",
+			values: []string{`<https://x.test/2; rel="next"`},
+			want:   "",
+		},
+		{
+			name:   "missing > before another link",
+			values: []string{`<https://x.test/1; rel="prev", <https://x.test/3>; rel="next"`},
+			want:   "https://x.test/3",
+		},
+		{
+			name:   "garbage before a link",
+			values: []string{`garbage; rel="next", <https://x.test/2>; rel="next"`},
+			want:   "https://x.test/2",
+		},
+		{
+			name:   "garbage",
+			values: []string{`;;,, "<>" rel=next ,<`},
+			want:   "",
+		},
+		{
+			name:   "empty",
+			values: nil,
+			want:   "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := NextLink(test.values, base); got != test.want {
+				t.Fatalf("NextLink(%q) = %q, want %q", test.values, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	got := ParseLinkHeader([]string{`<https://x.test/2>; Rel="next"; title="a \"quoted\", title"; rel="ignored"; anchor`})
+	want := []Link{{
+		Url:    "https://x.test/2",
+		Params: map[string]string{"rel": "next", "title": `a "quoted", title`, "anchor": ""},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseLinkHeader() = %+v, want %+v", got, want)
+	}
+}
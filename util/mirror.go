@@ -0,0 +1,126 @@
+package util
+
+import (
+	"errors"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"sync"
+)
+
+type compiledMirror struct {
+	regex       *regexp.Regexp
+	replacement string
+}
+
+var (
+	// mirrors are the rewrite rules of download urls, in the order they're tried.
+	mirrors      []compiledMirror
+	mirrorsMutex sync.RWMutex
+)
+
+// SetMirrors compiles the rewrite rules of download urls, the rules with an invalid regex are skipped.
+func SetMirrors(rules []Mirror) {
+	var compiled []compiledMirror
+	for _, rule := range rules {
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			Fprintfln("* err: Invalid regex of mirror: %s, %v", rule.Regex, err)
+			continue
+		}
+		compiled = append(compiled, compiledMirror{regex: regex, replacement: rule.Replacement})
+	}
+	mirrorsMutex.Lock()
+	mirrors = compiled
+	mirrorsMutex.Unlock()
+}
+
+// mirrorUrls returns the urls rewritten from url by the mirrors matching it, in the order of the rules and without
+// duplicates. url itself isn't included.
+func mirrorUrls(url string) []string {
+	mirrorsMutex.RLock()
+	defer mirrorsMutex.RUnlock()
+	seen := map[string]bool{url: true}
+	var urls []string
+	for _, mirror := range mirrors {
+		if !mirror.regex.MatchString(url) {
+			continue
+		}
+		rewritten := mirror.regex.ReplaceAllString(url, mirror.replacement)
+		if seen[rewritten] {
+			continue
+		}
+		seen[rewritten] = true
+		urls = append(urls, rewritten)
+	}
+	return urls
+}
+
+// assetSource is a url to get an asset from, with the header of its requests.
+type assetSource struct {
+	url    string
+	header http.Header
+	// mirror is only tried once, its failures don't count as retries.
+	mirror bool
+	// fallback is only tried if the source before it is not found.
+	fallback bool
+}
+
+// assetSources returns the mirrors of url, then url or the API asset endpoint if there's a token for it. The API
+// asset endpoint is the fallback of url otherwise.
+func assetSources(url, apiUrl string) []assetSource {
+	var sources []assetSource
+	for _, mirrored := range mirrorUrls(url) {
+		sources = append(sources, assetSource{url: mirrored, mirror: true})
+	}
+	if apiUrl == "" {
+		return append(sources, assetSource{url: url})
+	}
+	api := assetSource{url: apiUrl, header: http.Header{"Accept": {"application/octet-stream"}}}
+	if parsed, err := neturl.Parse(apiUrl); err == nil && HasToken(parsed.Host) {
+		return append(sources, api)
+	}
+	api.fallback = true
+	return append(sources, assetSource{url: url}, api)
+}
+
+// nextAssetSource reports whether the next one of sources is tried after the first one failed with err, instead of
+// retrying the first one.
+func nextAssetSource(sources []assetSource, err error) bool {
+	if len(sources) < 2 {
+		return false
+	}
+	if sources[0].mirror {
+		return true
+	}
+	var statusErr *StatusError
+	return sources[1].fallback && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// getAsset gets the asset from its sources in turn, and returns the first 200 response or the last error.
+func getAsset(client *http.Client, url, apiUrl string) (*http.Response, error) {
+	sources := assetSources(url, apiUrl)
+	for {
+		source := sources[0]
+		req, err := NewRequest(source.url)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range source.header {
+			req.Header[key] = values
+		}
+		resp, err := Do(client, req)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			err = &StatusError{Url: source.url, StatusCode: resp.StatusCode}
+		}
+		if err == nil {
+			return resp, nil
+		}
+		if !nextAssetSource(sources, err) {
+			return nil, err
+		}
+		SimplifiedPrintfln("* info: Failed to get: %s, %v, try the next source.", source.url, err)
+		sources = sources[1:]
+	}
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDownloadAssetMirror downloads the asset of an authenticated target, the mirror is tried first without the token
+// and the API asset endpoint is only used if the mirror fails.
+func TestDownloadAssetMirror(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/assets/1" || r.Header.Get("Authorization") != "token secret" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("api"))
+	}))
+	t.Cleanup(origin.Close)
+	var authorization string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if r.URL.Path != "/public/app.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("mirror"))
+	}))
+	t.Cleanup(mirror.Close)
+	SetToken(strings.TrimPrefix(origin.URL, "http://"), "token secret")
+	t.Cleanup(func() {
+		tokens = map[string]string{}
+		SetMirrors(nil)
+		Errors = nil
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		servedBy string
+	}{
+		{name: "public", path: "public/app.zip", servedBy: strings.TrimPrefix(mirror.URL, "http://")},
+		{name: "private", path: "private/app.zip", servedBy: strings.TrimPrefix(origin.URL, "http://")},
+	}
+	SetMirrors([]Mirror{{Regex: "^" + origin.URL + "/download/", Replacement: mirror.URL + "/"}})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authorization = ""
+			job := &downloadJob{
+				name:      "app.zip",
+				url:       origin.URL + "/download/" + test.path,
+				apiUrl:    origin.URL + "/api/assets/1",
+				parentDir: t.TempDir(),
+				fileName:  "app.zip",
+			}
+			err := downloadAsset(origin.Client(), job, &Target{User: "user", Repo: "repo"}, &Config{Retries: 1})
+			if err != nil || !job.done {
+				t.Fatalf("err = %v, errors = %v", err, Errors)
+			}
+			if job.historyAsset.ServedBy != test.servedBy {
+				t.Fatalf("served by %s, want %s", job.historyAsset.ServedBy, test.servedBy)
+			}
+			if authorization != "" {
+				t.Fatalf("the token is sent to the mirror: %s", authorization)
+			}
+		})
+	}
+}
+
+// TestFetchChecksumsPrivate reads the checksums of a private repo, whose assets are only served by the API asset
+// endpoint with the token.
+func TestFetchChecksumsPrivate(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/assets/2" || r.Header.Get("Authorization") != "token secret" ||
+			r.Header.Get("Accept") != "application/octet-stream" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(digest + "  app.zip\n"))
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		tokens = map[string]string{}
+		Errors = nil
+	})
+
+	release := &Release{Assets: []Asset{{Name: "checksums.txt", Url: server.URL + "/api/assets/2", BrowserDownloadURL: server.URL + "/download/checksums.txt"}}}
+	target := &Target{User: "user", Repo: "repo"}
+	for _, token := range []bool{false, true} {
+		if token {
+			SetToken(strings.TrimPrefix(server.URL, "http://"), "token secret")
+		}
+		Errors = nil
+		checksums, _ := fetchChecksums(server.Client(), release, target, nil)
+		if token != (checksums["app.zip"] == digest) {
+			t.Fatalf("token: %v, checksums = %v, errors = %v", token, checksums, Errors)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+var (
+	barPool      *pb.Pool
+	totalBar     *pb.ProgressBar
+	barPoolMutex sync.Mutex
+)
+
+// StartProgress starts the multi-bar view used when several downloads run at the same time.
+func StartProgress(concurrency int) {
+	if SimplifiedLog || concurrency <= 1 {
+		return
+	}
+
+	// The total bar is kept unfinished until StopProgress, otherwise the pool stops drawing once all current bars are done.
+	bar := pb.New64(0)
+	bar.SetTemplateString(`{{string . "prefix"}} {{counters . }} {{bar . }} {{etime . "%s"}}`)
+	bar.Set("prefix", "Total")
+	pool, err := pb.StartPool(bar)
+	if err != nil {
+		SimplifiedPrintfln("* info: Multi-bar view is unavailable, %v", err)
+		return
+	}
+
+	barPoolMutex.Lock()
+	barPool = pool
+	totalBar = bar
+	barPoolMutex.Unlock()
+}
+
+// StopProgress finishes the multi-bar view.
+func StopProgress() {
+	barPoolMutex.Lock()
+	defer barPoolMutex.Unlock()
+	if barPool != nil {
+		totalBar.Finish()
+		err := barPool.Stop()
+		if err != nil {
+			Fprintfln("* err: Failed to stop progress bars, %v", err)
+		}
+		barPool = nil
+		totalBar = nil
+	}
+}
+
+// newProgressBar creates a bar for a single download, either in the multi-bar view or standalone.
+func newProgressBar(name string, total int64) *pb.ProgressBar {
+	bar := pb.New64(total).SetTemplate(pb.Full)
+	bar.Set(pb.Bytes, true)
+	bar.Set(pb.SIBytesPrefix, true)
+	bar.SetRefreshRate(time.Second)
+
+	barPoolMutex.Lock()
+	defer barPoolMutex.Unlock()
+	if barPool != nil {
+		bar.Set("prefix", name)
+		totalBar.AddTotal(1)
+		barPool.Add(bar)
+	} else {
+		bar.Start()
+	}
+	return bar
+}
+
+// finishProgressBar finishes the bar and counts it in the total bar of the multi-bar view.
+func finishProgressBar(bar *pb.ProgressBar) {
+	bar.Finish()
+
+	barPoolMutex.Lock()
+	defer barPoolMutex.Unlock()
+	if totalBar != nil {
+		totalBar.Increment()
+	}
+}
@@ -0,0 +1,315 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Provider lists the releases of a repo on a forge.
+type Provider interface {
+	// GetRelease returns the releases of the page and the next page, which is -1 if there's no next page.
+	GetRelease(user, repo string, page int) ([]Release, int)
+	GetLatestRelease(user, repo string) *Release
+	GetReleaseByTag(user, repo, tag string) *Release
+}
+
+// pager keeps the urls of the "next" links of the pages, which are followed as given, and the number of items on the
+// pages before each page.
+type pager struct {
+	mutex    sync.Mutex
+	nextUrls map[int]string
+	offsets  map[int]int
+}
+
+func newPager() *pager {
+	return &pager{nextUrls: map[int]string{}, offsets: map[int]int{}}
+}
+
+// url returns the "next" link to the page, or api if there's none.
+func (p *pager) url(page int, api string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if nextUrl, ok := p.nextUrls[page]; ok {
+		return nextUrl
+	}
+	return api
+}
+
+// offset returns the number of items on the pages before the page.
+func (p *pager) offset(page int) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.offsets[page]
+}
+
+// next records the page after the page of n items, with nextUrl if it's not "", and returns it.
+func (p *pager) next(page, n int, nextUrl string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.offsets[page+1] = p.offsets[page] + n
+	if nextUrl != "" {
+		p.nextUrls[page+1] = nextUrl
+	}
+	return page + 1
+}
+
+// nextLink returns the page after the page of n items if resp has a "next" link, or -1 otherwise.
+func (p *pager) nextLink(page, n int, resp *http.Response) int {
+	nextUrl := NextLink(resp.Header.Values("Link"), resp.Request.URL)
+	if nextUrl == "" {
+		return -1
+	}
+	return p.next(page, n, nextUrl)
+}
+
+// logReleaseError logs the error of getting releases and records it for the repo.
+func logReleaseError(user, repo string, err error) {
+	var msg string
+	if statusErr, ok := err.(*StatusError); ok {
+		msg = fmt.Sprintf("* err: Failed to access, status code: %d.", statusErr.StatusCode)
+	} else {
+		msg = fmt.Sprintf("* err: Failed to get releases, %v", err)
+	}
+	Fprintfln(msg)
+	appendError(user, repo, msg)
+}
+
+// detectProvider guesses the provider from the host of the target url, GitHub is the default.
+func detectProvider(host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case host == "" || host == "github.com":
+		return ProviderGitHub
+	case host == "gitlab.com" || strings.Contains(host, "gitlab"):
+		return ProviderGitLab
+	case host == "codeberg.org" || strings.Contains(host, "gitea") || strings.Contains(host, "forgejo"):
+		return ProviderGitea
+	default:
+		return ProviderGitHub
+	}
+}
+
+// parseTarget fills provider, user and repo of the target from its url, and returns the parsed url, which is nil if
+// the target has no url. The user of GitLab is the full namespace of the project, e.g. "group/subgroup".
+func parseTarget(target *Target) (*url.URL, error) {
+	target.Provider = strings.ToLower(target.Provider)
+	if target.Url == "" {
+		if target.Provider == "" {
+			target.Provider = ProviderGitHub
+		}
+		return nil, nil
+	}
+
+	rawUrl := target.Url
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = "https://" + rawUrl
+	}
+	targetUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if target.Provider == "" {
+		target.Provider = detectProvider(targetUrl.Host)
+	}
+
+	urlSplit := strings.Split(strings.Trim(targetUrl.Path, "/"), "/")
+	if target.Provider == ProviderHttpIndex {
+		// The user is the host and the dirs of the index, and the repo is the last dir.
+		if targetUrl.Host == "" {
+			return nil, fmt.Errorf("no host in url")
+		}
+		target.User = targetUrl.Host
+		target.Repo = targetUrl.Host
+		if len(urlSplit) > 0 && urlSplit[0] != "" {
+			target.User = strings.Join(append([]string{targetUrl.Host}, urlSplit[:len(urlSplit)-1]...), "/")
+			target.Repo = urlSplit[len(urlSplit)-1]
+		}
+		return targetUrl, nil
+	}
+	if targetUrl.Host == "" || len(urlSplit) < 2 {
+		return nil, fmt.Errorf("no user or repo in url")
+	}
+	target.Repo = strings.TrimSuffix(urlSplit[len(urlSplit)-1], ".git")
+	if target.Provider == ProviderGitLab {
+		target.User = strings.Join(urlSplit[:len(urlSplit)-1], "/")
+	} else {
+		target.User = urlSplit[len(urlSplit)-2]
+	}
+	return targetUrl, nil
+}
+
+// apiBaseOf returns the API base of the target parsed by parseTarget. For GitHub, it's detected as a GitHub
+// Enterprise Server if the host of url isn't github.com, or [api_base] of config is used if the target has no url.
+func apiBaseOf(target *Target, targetUrl *url.URL, config *Config) string {
+	if target.ApiBase != "" {
+		return strings.TrimSuffix(target.ApiBase, "/")
+	}
+
+	switch target.Provider {
+	case ProviderGitea, ProviderForgejo:
+		if targetUrl != nil {
+			return fmt.Sprintf("%s://%s/api/v1", targetUrl.Scheme, targetUrl.Host)
+		}
+	case ProviderGitLab:
+		if targetUrl != nil {
+			return fmt.Sprintf("%s://%s/api/v4", targetUrl.Scheme, targetUrl.Host)
+		}
+	case ProviderHttpIndex:
+	default:
+		if targetUrl != nil && !strings.EqualFold(targetUrl.Host, "github.com") {
+			return fmt.Sprintf("%s://%s/api/v3", targetUrl.Scheme, targetUrl.Host)
+		}
+		if targetUrl == nil && config.ApiBase != "" {
+			return strings.TrimSuffix(config.ApiBase, "/")
+		}
+		return GitHubApi
+	}
+	return ""
+}
+
+// authorizationOf returns the Authorization header of the token for the provider.
+func authorizationOf(provider, token string) string {
+	switch provider {
+	case ProviderGitea, ProviderForgejo:
+		return "token " + token
+	default:
+		return "Bearer " + token
+	}
+}
+
+// registerTokens registers the global token and the token of each target for their hosts, and returns the targets
+// whose tokens don't conflict with the token of a host.
+func registerTokens(config *Config) []Target {
+	if config.Token != "" {
+		apiBase := GitHubApi
+		if config.ApiBase != "" {
+			apiBase = config.ApiBase
+		}
+		apiUrl, err := url.Parse(apiBase)
+		if err != nil {
+			Fprintfln("* err: Failed to parse api base: %s, %v", apiBase, err)
+		} else {
+			SetToken(apiUrl.Host, authorizationOf(ProviderGitHub, config.Token))
+			if apiUrl.Host == "api.github.com" {
+				SetToken("github.com", authorizationOf(ProviderGitHub, config.Token))
+			}
+		}
+	}
+
+	var targets []Target
+	for _, target := range config.Targets {
+		err := registerTargetToken(target, config)
+		if err != nil {
+			name := target
+			parseTarget(&name)
+			if isDynamicTarget(&target) {
+				name.User = dynamicTargetName(&target)
+			}
+			msg := fmt.Sprintf("* err: Refused the target, %v", err)
+			Fprintfln(msg)
+			appendError(name.User, name.Repo, msg)
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// registerTargetToken registers the token of the target for the hosts of its API base and url. Nothing is registered
+// if another token is registered for any of the hosts.
+func registerTargetToken(target Target, config *Config) error {
+	if target.Token == "" {
+		return nil
+	}
+	targetUrl, err := parseTarget(&target)
+	if err != nil {
+		return nil
+	}
+	authorization := authorizationOf(target.Provider, target.Token)
+	var hosts []string
+	apiUrl, err := url.Parse(apiBaseOf(&target, targetUrl, config))
+	if err == nil && apiUrl.Host != "" {
+		hosts = append(hosts, apiUrl.Host)
+	}
+	if targetUrl != nil {
+		hosts = append(hosts, targetUrl.Host)
+	} else if apiUrl != nil && apiUrl.Host == "api.github.com" {
+		hosts = append(hosts, "github.com")
+	}
+
+	for _, host := range hosts {
+		if old := tokenOf(host); old != "" && old != authorization {
+			return fmt.Errorf("another token is configured for %s, a host only has one token", host)
+		}
+	}
+	for _, host := range hosts {
+		SetToken(host, authorization)
+	}
+	return nil
+}
+
+// newProvider creates the provider of the target parsed by parseTarget.
+func newProvider(client *http.Client, target *Target, apiBase string, config *Config) (Provider, error) {
+	switch target.Source {
+	case "", SourceReleases:
+	case SourceTags:
+		if target.Provider != ProviderGitHub {
+			return nil, fmt.Errorf("source: %s is only supported by provider: %s", SourceTags, ProviderGitHub)
+		}
+		return NewTags(client, apiBase, tagLookupLimit(target), tagLookupUntilLocal(target)), nil
+	case SourceArtifacts:
+		if target.Provider != ProviderGitHub {
+			return nil, fmt.Errorf("source: %s is only supported by provider: %s", SourceArtifacts, ProviderGitHub)
+		}
+		if target.Workflow == "" {
+			return nil, fmt.Errorf("workflow is required by source: %s", SourceArtifacts)
+		}
+		if apiUrl, err := url.Parse(apiBase); err == nil && !HasToken(apiUrl.Host) {
+			SimplifiedPrintfln("* info: Artifacts can only be downloaded with a token of: %s.", apiUrl.Host)
+		}
+		return NewArtifacts(client, apiBase, target.Workflow, target.Branch), nil
+	default:
+		return nil, fmt.Errorf("unknown source: %s", target.Source)
+	}
+
+	switch target.Provider {
+	case ProviderGitHub:
+		if useGraphQL(target, apiBase, config) {
+			return NewGitHubGraphQL(client, apiBase), nil
+		}
+		return NewGitHub(client, apiBase, config.PerPage), nil
+	case ProviderGitea, ProviderForgejo:
+		if apiBase == "" {
+			return nil, fmt.Errorf("url or api_base is required by provider: %s", target.Provider)
+		}
+		return NewGitea(client, apiBase), nil
+	case ProviderGitLab:
+		if apiBase == "" {
+			return nil, fmt.Errorf("url or api_base is required by provider: %s", target.Provider)
+		}
+		return NewGitLab(client, apiBase), nil
+	case ProviderHttpIndex:
+		if target.Url == "" {
+			return nil, fmt.Errorf("url is required by provider: %s", target.Provider)
+		}
+		var versionRegex *regexp.Regexp = nil
+		if target.VersionRegex != "" {
+			var err error
+			versionRegex, err = regexp.Compile(target.VersionRegex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version_regex: %v", err)
+			}
+		}
+		indexUrl := target.Url
+		if !strings.Contains(indexUrl, "://") {
+			indexUrl = "https://" + indexUrl
+		}
+		return NewHttpIndex(client, indexUrl, versionRegex), nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", target.Provider)
+	}
+}
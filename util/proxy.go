@@ -0,0 +1,72 @@
+package util
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ProxyDirect is the proxy of the rules which connect to their hosts directly.
+const ProxyDirect = "direct"
+
+// proxyRule routes the requests to the hosts matching regex through proxy, or directly if proxy is nil.
+type proxyRule struct {
+	regex *regexp.Regexp
+	proxy *url.URL
+}
+
+// parseProxy parses an http, https, socks5 or socks5h proxy url.
+func parseProxy(proxy string) *url.URL {
+	proxyUrl, err := url.Parse(proxy)
+	if err == nil && proxyUrl.Host == "" {
+		err = os.ErrInvalid
+	}
+	if err != nil {
+		Fprintfln("Failed to parse proxy: %s, %v", proxy, err)
+		os.Exit(Error)
+	}
+	switch strings.ToLower(proxyUrl.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		Fprintfln("Failed to parse proxy: %s, unsupported scheme: %s", proxy, proxyUrl.Scheme)
+		os.Exit(Error)
+	}
+	return proxyUrl
+}
+
+// proxyOf returns the proxy func of the transport. The first rule of [proxies] matching the host of a request is used,
+// otherwise it's [proxy_http], or the proxy of HTTP_PROXY/HTTPS_PROXY/NO_PROXY if [proxy_http] is empty.
+func proxyOf(config *Config) func(*http.Request) (*url.URL, error) {
+	var rules []proxyRule
+	for _, p := range config.Proxies {
+		regex, err := regexp.Compile(p.Host)
+		if err != nil {
+			Fprintfln("Failed to parse host of proxy: %s, %v", p.Host, err)
+			os.Exit(Error)
+		}
+		rule := proxyRule{regex: regex}
+		if !strings.EqualFold(p.Proxy, ProxyDirect) && p.Proxy != "" {
+			rule.proxy = parseProxy(p.Proxy)
+		}
+		rules = append(rules, rule)
+	}
+
+	fallback := http.ProxyFromEnvironment
+	if config.ProxyHttp != "" {
+		fallback = http.ProxyURL(parseProxy(config.ProxyHttp))
+	}
+	if len(rules) == 0 {
+		return fallback
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		host := req.URL.Hostname()
+		for _, rule := range rules {
+			if rule.regex.MatchString(host) {
+				return rule.proxy, nil
+			}
+		}
+		return fallback(req)
+	}
+}
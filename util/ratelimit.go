@@ -0,0 +1,198 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxRateLimitWait is long enough to wait for the primary rate limit of GitHub to reset.
+const DefaultMaxRateLimitWait = 3600
+
+// secondaryRateLimitBackoff is the first backoff of a secondary rate limit without Retry-After, it's doubled each time.
+const secondaryRateLimitBackoff = time.Minute
+
+type rateLimit struct {
+	limit     string
+	remaining string
+	reset     time.Time
+	// blockedUntil makes other requests to the host wait as well, instead of hitting the limit again.
+	blockedUntil time.Time
+	waits        int
+	waited       time.Duration
+	gaveUp       int
+}
+
+var (
+	rateLimits       = map[string]*rateLimit{}
+	rateLimitsMutex  sync.Mutex
+	maxRateLimitWait = DefaultMaxRateLimitWait * time.Second
+)
+
+// SetMaxRateLimitWait sets the max seconds a request waits for rate limits in total, 0 means the default and a
+// negative one means never waiting.
+func SetMaxRateLimitWait(seconds int) {
+	if seconds == 0 {
+		seconds = DefaultMaxRateLimitWait
+	} else if seconds < 0 {
+		seconds = 0
+	}
+	maxRateLimitWait = time.Duration(seconds) * time.Second
+}
+
+// Do sends the request, and sends it again after waiting if it's rejected by a rate limit, until the total wait
+// would exceed the max wait. X-RateLimit-* headers of every response are recorded for PrintRateLimits.
+func Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Host)
+	var waited time.Duration = 0
+	backoff := secondaryRateLimitBackoff
+	for {
+		rateLimitsMutex.Lock()
+		state := rateLimits[host]
+		var blocked time.Duration = 0
+		if state != nil {
+			blocked = time.Until(state.blockedUntil)
+		}
+		rateLimitsMutex.Unlock()
+		if blocked > 0 {
+			if waited+blocked > maxRateLimitWait {
+				return nil, &StatusError{Url: req.URL.String(), StatusCode: http.StatusTooManyRequests, RateLimit: true}
+			}
+			time.Sleep(blocked)
+			waited += blocked
+		}
+
+		if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+			// The body has been read by the last attempt.
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		wait, limited := checkRateLimit(host, resp, backoff)
+		if !limited {
+			return resp, nil
+		}
+		if waited+wait > maxRateLimitWait {
+			Fprintfln("* err: Rate limit of %s exceeded, give up waiting %s for it.", host, wait.Round(time.Second))
+			rateLimitsMutex.Lock()
+			rateLimitsState(host).gaveUp++
+			rateLimitsMutex.Unlock()
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		Fprintfln("* info: Rate limit of %s exceeded, wait %s.", host, wait.Round(time.Second))
+		rateLimitsMutex.Lock()
+		state = rateLimitsState(host)
+		state.waits++
+		state.waited += wait
+		if until := time.Now().Add(wait); until.After(state.blockedUntil) {
+			state.blockedUntil = until
+		}
+		rateLimitsMutex.Unlock()
+		if wait == backoff {
+			// Only the backoff without any hint from the server grows.
+			backoff *= 2
+		}
+	}
+}
+
+// rateLimitsState returns the state of the host, the caller must hold rateLimitsMutex.
+func rateLimitsState(host string) *rateLimit {
+	state, ok := rateLimits[host]
+	if !ok {
+		state = &rateLimit{}
+		rateLimits[host] = state
+	}
+	return state
+}
+
+// checkRateLimit records the rate limit headers of the response, and returns how long to wait if it's rejected by
+// the primary rate limit (X-RateLimit-Remaining is 0), or by a secondary one (Retry-After or the message of body).
+func checkRateLimit(host string, resp *http.Response, backoff time.Duration) (time.Duration, bool) {
+	limit := resp.Header.Get("X-RateLimit-Limit")
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	var reset time.Time
+	if resetUnix, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(resetUnix, 0)
+	}
+	if limit != "" || remaining != "" {
+		rateLimitsMutex.Lock()
+		state := rateLimitsState(host)
+		state.limit = limit
+		state.remaining = remaining
+		state.reset = reset
+		rateLimitsMutex.Unlock()
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		var wait time.Duration
+		parsed := false
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			wait, parsed = time.Duration(seconds)*time.Second, true
+		} else if date, err := http.ParseTime(retryAfter); err == nil {
+			wait, parsed = time.Until(date), true
+		}
+		if parsed {
+			// A date in the past still waits, so that the waits are counted against the max wait.
+			if wait < time.Second {
+				wait = time.Second
+			}
+			return wait, true
+		}
+	}
+	if remaining == "0" && !reset.IsZero() {
+		wait := time.Until(reset) + time.Second
+		if wait < time.Second {
+			wait = time.Second
+		}
+		return wait, true
+	}
+
+	// A secondary rate limit may come without any header, only its message tells.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err == nil && strings.Contains(strings.ToLower(string(body)), "rate limit") {
+		return backoff, true
+	}
+	return backoff, resp.StatusCode == http.StatusTooManyRequests
+}
+
+// PrintRateLimits prints the last known rate limit and the waits of each host.
+func PrintRateLimits() {
+	rateLimitsMutex.Lock()
+	defer rateLimitsMutex.Unlock()
+	if len(rateLimits) == 0 {
+		return
+	}
+
+	var hosts []string
+	for host := range rateLimits {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	Printfln("Rate limits:")
+	for _, host := range hosts {
+		state := rateLimits[host]
+		reset := "-"
+		if !state.reset.IsZero() {
+			reset = state.reset.Format("2006-01-02 15:04:05")
+		}
+		Printfln("Host: %s, remaining: %s/%s, reset at: %s, waits: %d, waited: %s, gave up: %d", host, state.remaining, state.limit, reset, state.waits, state.waited.Round(time.Second), state.gaveUp)
+	}
+}
@@ -2,201 +2,368 @@ package util
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/cheggaaa/pb/v3"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-var token = ""
+// PartSuffix is appended to the destination of an unfinished download.
+const PartSuffix = ".part"
 
-func Get(client *http.Client, url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// ValidatorSuffix is appended to the partial file for the file keeping its ETag or Last-Modified, which is sent as
+// If-Range when resuming it.
+const ValidatorSuffix = ".validator"
+
+var (
+	// tokens maps hosts to the Authorization header sent to them, a token is never sent to other hosts.
+	tokens      = map[string]string{}
+	tokensMutex sync.RWMutex
+)
+
+// SetToken sets the Authorization header sent to the host, or removes it if authorization is "".
+func SetToken(host, authorization string) {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+	host = strings.ToLower(host)
+	if authorization == "" {
+		delete(tokens, host)
+		return
+	}
+	tokens[host] = authorization
+}
+
+// tokenOf returns the Authorization header sent to the host, or "" if there is no token for it.
+func tokenOf(host string) string {
+	tokensMutex.RLock()
+	defer tokensMutex.RUnlock()
+	return tokens[strings.ToLower(host)]
+}
+
+// HasToken reports whether a token is configured for the host.
+func HasToken(host string) bool {
+	tokensMutex.RLock()
+	defer tokensMutex.RUnlock()
+	_, ok := tokens[strings.ToLower(host)]
+	return ok
+}
+
+func NewRequest(url string) (*http.Request, error) {
+	return newRequest("GET", url, nil)
+}
+
+// newRequest creates a request with the Authorization header of its host.
+func newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
+	tokensMutex.RLock()
+	authorization := tokens[strings.ToLower(req.URL.Host)]
+	tokensMutex.RUnlock()
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
 	}
-	return client.Do(req)
+	return req, nil
 }
 
-func GetHttpClient(proxyHttp string, t string, timeout int) *http.Client {
-	var client http.Client
-	if proxyHttp != "" {
-		proxy, err := url.Parse(proxyHttp)
-		if err != nil {
-			Fprintfln("Failed to parse proxy: %s, %v", proxyHttp, err)
-			os.Exit(Error)
-		}
-		client = http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-			Transport: &http.Transport{
-				Proxy: http.ProxyURL(proxy),
-			},
-		}
-	} else {
-		client = http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-		}
+func Get(client *http.Client, url string) (*http.Response, error) {
+	req, err := NewRequest(url)
+	if err != nil {
+		return nil, err
 	}
-	token = t
-	return &client
+	return Do(client, req)
 }
 
-func GetRelease(client *http.Client, user, repo string, page int) ([]Release, int) {
-	api := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases?page=%d", user, repo, page)
-
-	resp, err := Get(client, api)
+// GetJson gets the url with GetCached and decodes the body into v. A response other than 200 is returned as
+// StatusError, otherwise the response is returned for its header, even if the body fails to decode.
+func GetJson(client *http.Client, url string, v interface{}) (*http.Response, error) {
+	resp, err := GetCached(client, url)
 	if err != nil {
-		msg := fmt.Sprintf("* err: Failed to get: %s, %v", api, err)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil, -1
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("* err: Failed to access, status code: %d.", resp.StatusCode)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil, -1
-	}
-
-	nextPage := -1
-	links := resp.Header.Get("Link")
-	link := strings.Split(links, ", ")
-	for _, item := range link {
-		bin := strings.Split(item, "; ")
-		if len(bin) == 2 {
-			rel := strings.Replace(strings.Split(bin[1], "rel=")[1], "\"", "", -1)
-			if rel == "next" {
-				nextPage, _ = strconv.Atoi(strings.Replace(strings.Split(bin[0], "?page=")[1], ">", "", -1))
-			}
-		}
+		return nil, &StatusError{Url: url, StatusCode: resp.StatusCode}
 	}
+	return resp, json.NewDecoder(resp.Body).Decode(v)
+}
 
-	var releases []Release
-	err = json.NewDecoder(resp.Body).Decode(&releases)
+func Post(client *http.Client, url, contentType string, body []byte) (*http.Response, error) {
+	req, err := newRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
-		msg := fmt.Sprintf("* err: Failed to parse release body: %v", err)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil, -1
+		return nil, err
 	}
-
-	return releases, nextPage
+	req.Header.Set("Content-Type", contentType)
+	return Do(client, req)
 }
 
-func GetLatestRelease(client *http.Client, user, repo string) *Release {
-	api := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", user, repo)
-
-	resp, err := Get(client, api)
-	if err != nil {
-		msg := fmt.Sprintf("* err: Failed to get: %s, %v", api, err)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil
+// GetHttpClient creates the client of both API and download requests, with the proxies and TLS settings of config.
+func GetHttpClient(config *Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyOf(config)
+	transport.TLSClientConfig = tlsConfigOf(config)
+	return &http.Client{
+		Timeout:       time.Duration(config.Timeout) * time.Second,
+		Transport:     transport,
+		CheckRedirect: checkRedirect,
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("* err: Failed to access, status code: %d.", resp.StatusCode)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil
+// checkRedirect drops the Authorization header when redirected to another host, e.g. the signed url of the storage
+// host for an asset, even if it's a subdomain of the original host.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		req.Header.Del("Authorization")
 	}
+	return nil
+}
 
-	var release Release
-	err = json.NewDecoder(resp.Body).Decode(&release)
-	if err != nil {
-		msg := fmt.Sprintf("* err: Failed to parse release body: %v", err)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil
+// parseContentRange parses "bytes start-end/size" or "bytes */size", -1 is returned for the unknown parts.
+func parseContentRange(contentRange string) (int64, int64, error) {
+	var start, size int64 = -1, -1
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return start, size, fmt.Errorf("invalid content range: %s", contentRange)
+	}
+	rng, sizeStr, found := strings.Cut(strings.TrimPrefix(contentRange, "bytes "), "/")
+	if !found {
+		return start, size, fmt.Errorf("invalid content range: %s", contentRange)
 	}
+	if sizeStr != "*" {
+		var err error
+		size, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			return start, size, fmt.Errorf("invalid content range: %s", contentRange)
+		}
+	}
+	if rng != "*" {
+		startStr, _, _ := strings.Cut(rng, "-")
+		var err error
+		start, err = strconv.ParseInt(startStr, 10, 64)
+		if err != nil {
+			return start, size, fmt.Errorf("invalid content range: %s", contentRange)
+		}
+	}
+	return start, size, nil
+}
 
-	return &release
+// DownloadResult describes a completed download.
+type DownloadResult struct {
+	Size   int64
+	Sha256 string
+	// Host is the host which served the file, after redirects.
+	Host string
 }
 
-func GetReleaseByTag(client *http.Client, user, repo, tag string) *Release {
-	api := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/tags/%s", user, repo, tag)
+// Download downloads url to the partial file "dst.part", which is resumed with If-Range next time if it fails, and
+// renames it to dst once the size and verify are checked.
+func Download(client *http.Client, url string, header http.Header, dst string, size int64, verify func(file string, result *DownloadResult) error) (*DownloadResult, error) {
+	part := dst + PartSuffix
+	var offset int64 = 0
+	info, err := os.Stat(part)
+	if err == nil && info.Mode().IsRegular() {
+		offset = info.Size()
+	}
+	validator := ""
+	if offset > 0 {
+		data, err := os.ReadFile(part + ValidatorSuffix)
+		validator = strings.TrimSpace(string(data))
+		if err != nil || validator == "" {
+			// Without a validator, the partial file may be left by another version of the file.
+			SimplifiedPrintfln("* info: No validator of %s, download %s from the beginning.", part, dst)
+			offset = 0
+		}
+	}
 
-	resp, err := Get(client, api)
+	req, err := NewRequest(url)
 	if err != nil {
-		msg := fmt.Sprintf("* err: Failed to get: %s, %v", api, err)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		msg := fmt.Sprintf("* err: Tag not found: %s.", tag)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil
-	} else if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("* err: Failed to access, status code: %d.", resp.StatusCode)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil
+	for key, values := range header {
+		req.Header[key] = values
 	}
-
-	var release Release
-	err = json.NewDecoder(resp.Body).Decode(&release)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+	resp, err := Do(client, req)
 	if err != nil {
-		msg := fmt.Sprintf("* err: Failed to parse release body: %v", err)
-		Fprintfln(msg)
-		Errors = append(Errors, Err{User: user, Repo: repo, Msg: msg})
-		return nil
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	return &release
-}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case offset > 0 && (resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable):
+	default:
+		rateLimit := (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) && resp.Header.Get("X-RateLimit-Remaining") == "0"
+		return nil, &StatusError{Url: url, StatusCode: resp.StatusCode, RateLimit: rateLimit}
+	}
 
-func Download(client *http.Client, url, dst string) error {
-	resp, err := Get(client, url)
-	if err != nil {
-		return err
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	hash := sha256.New()
+	if offset > 0 {
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			start, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+			if err != nil || start != offset {
+				removePart(part)
+				return nil, fmt.Errorf("unexpected content range: \"%s\" for %d bytes of %s, the partial file is discarded", resp.Header.Get("Content-Range"), offset, part)
+			}
+			SimplifiedPrintfln("* info: Resume: %s from %d bytes.", part, offset)
+			flag = os.O_WRONLY | os.O_APPEND
+			err = hashFile(hash, part, offset)
+			if err != nil {
+				return nil, err
+			}
+		case http.StatusRequestedRangeNotSatisfiable:
+			_, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+			if err == nil && total == offset {
+				// The partial file has been completed by the last attempt.
+				if size > 0 && offset != size {
+					return nil, &SizeError{Url: url, Expected: size, Actual: offset}
+				}
+				err = hashFile(hash, part, offset)
+				if err != nil {
+					return nil, err
+				}
+				result := &DownloadResult{Size: offset, Sha256: hex.EncodeToString(hash.Sum(nil)), Host: resp.Request.URL.Host}
+				if verify != nil {
+					err = verify(part, result)
+					if err != nil {
+						removePart(part)
+						return nil, err
+					}
+				}
+				removeValidator(part)
+				return result, commitFile(part, dst)
+			}
+			removePart(part)
+			return nil, fmt.Errorf("range of %d bytes is not satisfiable for %s, the partial file is discarded", offset, part)
+		default:
+			SimplifiedPrintfln("* info: The server refused the range request or the file has changed, download %s from the beginning.", dst)
+			offset = 0
+		}
+	}
+	resumable := resp.Header.Get("Accept-Ranges") != "none"
+	if offset == 0 {
+		err = writeValidator(part, resp.Header)
+		if err != nil {
+			return nil, err
+		}
 	}
-	defer resp.Body.Close()
 
-	dstFile, err := os.Create(dst)
+	partFile, err := os.OpenFile(part, flag, 0644)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer dstFile.Close()
 
+	var written int64
 	if SimplifiedLog {
-		writer := bufio.NewWriter(dstFile)
-		_, err = io.Copy(writer, resp.Body)
-		if err != nil {
-			return err
-		}
-		err = writer.Flush()
-		if err != nil {
-			return err
+		writer := bufio.NewWriter(partFile)
+		written, err = io.Copy(io.MultiWriter(writer, hash), resp.Body)
+		if err == nil {
+			err = writer.Flush()
 		}
 	} else {
-		bar := pb.Full.Start64(resp.ContentLength)
-		bar.Set(pb.Bytes, true)
-		bar.Set(pb.SIBytesPrefix, true)
-		bar.SetRefreshRate(time.Second)
-
+		total := resp.ContentLength
+		if total >= 0 {
+			total += offset
+		}
+		bar := newProgressBar(filepath.Base(dst), total)
+		bar.SetCurrent(offset)
 		reader := bar.NewProxyReader(resp.Body)
-		_, err = io.Copy(dstFile, reader)
+		written, err = io.Copy(io.MultiWriter(partFile, hash), reader)
+		finishProgressBar(bar)
+	}
+	if err == nil {
+		err = partFile.Sync()
+	}
+	closeErr := partFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && resp.ContentLength >= 0 && written != resp.ContentLength {
+		err = &LengthError{Url: url, Expected: resp.ContentLength, Written: written}
+	}
+	if err != nil {
+		if !resumable {
+			// The server doesn't accept range requests, so there is no way to resume it.
+			removePart(part)
+		}
+		return nil, err
+	}
+	if size > 0 && offset+written != size {
+		return nil, &SizeError{Url: url, Expected: size, Actual: offset + written}
+	}
+	result := &DownloadResult{Size: offset + written, Sha256: hex.EncodeToString(hash.Sum(nil)), Host: resp.Request.URL.Host}
+	if verify != nil {
+		err = verify(part, result)
 		if err != nil {
-			return err
+			removePart(part)
+			return nil, err
 		}
-		bar.Finish()
 	}
 
-	return nil
+	// The part is fsynced above, dst never refers to an incomplete file.
+	removeValidator(part)
+	return result, os.Rename(part, dst)
+}
+
+// writeValidator keeps the strong ETag, or the Last-Modified of the response next to the partial file, it's removed
+// if there is neither of them, so that the partial file is never resumed.
+func writeValidator(part string, header http.Header) error {
+	validator := header.Get("ETag")
+	if strings.HasPrefix(validator, "W/") {
+		// Weak ETags aren't allowed in If-Range.
+		validator = ""
+	}
+	if validator == "" {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		removeValidator(part)
+		return nil
+	}
+	return os.WriteFile(part+ValidatorSuffix, []byte(validator), 0644)
+}
+
+func removeValidator(part string) {
+	err := os.Remove(part + ValidatorSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		Fprintfln("* err: Failed delete: %s.", part+ValidatorSuffix)
+	}
+}
+
+// hashFile writes the first n bytes of the file to hash.
+func hashFile(hash io.Writer, name string, n int64) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.CopyN(hash, file, n)
+	return err
+}
+
+func removePart(part string) {
+	err := os.Remove(part)
+	if err != nil && !os.IsNotExist(err) {
+		Fprintfln("* err: Failed delete: %s.", part)
+	}
+	removeValidator(part)
 }
@@ -0,0 +1,285 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/blake2b"
+)
+
+// maxSignatureSize limits the signature assets read into memory.
+const maxSignatureSize = 1 << 20
+
+// SignatureError means the downloaded file isn't signed by the configured public key.
+type SignatureError struct {
+	Name string
+	Msg  string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature verification of %s failed, %s", e.Name, e.Msg)
+}
+
+// Retryable is always false, the signature won't change by downloading again.
+func (e *SignatureError) Retryable() bool {
+	return false
+}
+
+// signatureSuffixes returns the suffixes of the detached signature assets of the signature type.
+func signatureSuffixes(signatureType string) []string {
+	switch signatureType {
+	case SignatureMinisign:
+		return []string{".minisig"}
+	case SignatureCosign:
+		return []string{".sig"}
+	case SignatureGpg:
+		return []string{".asc", ".sig"}
+	default:
+		return nil
+	}
+}
+
+// isSignatureAsset reports whether the asset name is a detached signature of the configured type.
+func isSignatureAsset(name string, target *Target) bool {
+	for _, suffix := range signatureSuffixes(target.Verify.Signature.Type) {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchSignatures downloads the detached signatures of the release into a map of signed asset name to signature.
+func fetchSignatures(client *http.Client, release *Release, target *Target) map[string][]byte {
+	signatures := map[string][]byte{}
+	names := map[string]bool{}
+	for _, asset := range release.Assets {
+		names[asset.Name] = true
+	}
+	for _, asset := range release.Assets {
+		if !isSignatureAsset(asset.Name, target) {
+			continue
+		}
+		signedName := ""
+		for _, suffix := range signatureSuffixes(target.Verify.Signature.Type) {
+			if strings.HasSuffix(asset.Name, suffix) && names[strings.TrimSuffix(asset.Name, suffix)] {
+				signedName = strings.TrimSuffix(asset.Name, suffix)
+			}
+		}
+		if signedName == "" {
+			continue
+		}
+
+		resp, err := getAsset(client, asset.BrowserDownloadURL, asset.Url)
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to get: %s, %v", asset.BrowserDownloadURL, err)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+			continue
+		}
+		signature, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+		resp.Body.Close()
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to read signature: %s, %v", asset.Name, err)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+			continue
+		}
+		signatures[signedName] = signature
+	}
+	return signatures
+}
+
+// verifySignature checks the content of the asset name against its detached signature.
+func verifySignature(content io.Reader, name string, signatures map[string][]byte, target *Target) error {
+	signature, ok := signatures[name]
+	if !ok {
+		if target.Verify.Signature.IsRequired() {
+			return &SignatureError{Name: name, Msg: "no signature found"}
+		}
+		// It's still reported, since a removed signature asset looks the same.
+		msg := fmt.Sprintf("* err: No signature found for %s, skip verifying since required is false.", name)
+		Fprintfln(msg)
+		appendError(target.User, target.Repo, msg)
+		return nil
+	}
+
+	publicKey, err := os.ReadFile(target.Verify.Signature.PublicKey)
+	if err != nil {
+		return &SignatureError{Name: name, Msg: err.Error()}
+	}
+	switch target.Verify.Signature.Type {
+	case SignatureMinisign:
+		err = VerifyMinisign(content, signature, publicKey)
+	case SignatureCosign:
+		err = VerifyCosign(content, signature, publicKey)
+	case SignatureGpg:
+		err = VerifyGpg(content, signature, publicKey)
+	default:
+		err = fmt.Errorf("unknown signature type: %s", target.Verify.Signature.Type)
+	}
+	if err != nil {
+		return &SignatureError{Name: name, Msg: err.Error()}
+	}
+	SimplifiedPrintfln("* info: Signature verified: %s.", name)
+	return nil
+}
+
+// verifyFileSignature checks the downloaded file against the detached signature of the asset name.
+func verifyFileSignature(file, name string, signatures map[string][]byte, target *Target) error {
+	content, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	return verifySignature(content, name, signatures, target)
+}
+
+// readMinisignLines returns the lines of a minisign file without the untrusted comment.
+func readMinisignLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// VerifyMinisign verifies content against a minisign signature, both legacy ("Ed") and prehashed ("ED") ones.
+func VerifyMinisign(content io.Reader, signature, publicKey []byte) error {
+	keyLines := readMinisignLines(publicKey)
+	if len(keyLines) < 1 {
+		return fmt.Errorf("invalid minisign public key")
+	}
+	key, err := base64.StdEncoding.DecodeString(keyLines[0])
+	if err != nil || len(key) != 42 || string(key[:2]) != "Ed" {
+		return fmt.Errorf("invalid minisign public key")
+	}
+
+	sigLines := readMinisignLines(signature)
+	if len(sigLines) < 3 || !strings.HasPrefix(sigLines[1], "trusted comment: ") {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigLines[0])
+	if err != nil || len(sig) != 74 {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(sigLines[2])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign global signature")
+	}
+	if !bytes.Equal(sig[2:10], key[2:10]) {
+		return fmt.Errorf("the signature is signed by another key")
+	}
+
+	var message []byte
+	switch string(sig[:2]) {
+	case "ED":
+		hash, _ := blake2b.New512(nil)
+		_, err = io.Copy(hash, content)
+		message = hash.Sum(nil)
+	case "Ed":
+		message, err = io.ReadAll(content)
+	default:
+		return fmt.Errorf("unknown minisign signature algorithm: %s", sig[:2])
+	}
+	if err != nil {
+		return err
+	}
+
+	publicKeyBytes := ed25519.PublicKey(key[10:])
+	if !ed25519.Verify(publicKeyBytes, message, sig[10:]) {
+		return fmt.Errorf("invalid signature")
+	}
+	trustedComment := strings.TrimPrefix(sigLines[1], "trusted comment: ")
+	globalMessage := append(append([]byte{}, sig[10:]...), trustedComment...)
+	if !ed25519.Verify(publicKeyBytes, globalMessage, globalSig) {
+		return fmt.Errorf("invalid global signature")
+	}
+	return nil
+}
+
+// VerifyCosign verifies content against a base64 signature of "cosign sign-blob" made by a PEM encoded ECDSA,
+// Ed25519 or RSA key.
+func VerifyCosign(content io.Reader, signature, publicKey []byte) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return fmt.Errorf("invalid PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid base64 signature, %v", err)
+	}
+
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		message, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(key, message, sig) {
+			return fmt.Errorf("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		hash := sha256.New()
+		_, err = io.Copy(hash, content)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.VerifyASN1(key, hash.Sum(nil), sig) {
+			return fmt.Errorf("invalid signature")
+		}
+	case *rsa.PublicKey:
+		hash := sha256.New()
+		_, err = io.Copy(hash, content)
+		if err != nil {
+			return err
+		}
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash.Sum(nil), sig)
+		if err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type: %T", key)
+	}
+	return nil
+}
+
+// VerifyGpg verifies content against an armored or binary detached OpenPGP signature,
+// publicKey can be an armored or binary key ring.
+func VerifyGpg(content io.Reader, signature, publicKey []byte) error {
+	keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(publicKey))
+	if err != nil {
+		keyRing, err = openpgp.ReadKeyRing(bytes.NewReader(publicKey))
+		if err != nil {
+			return fmt.Errorf("invalid OpenPGP public key, %v", err)
+		}
+	}
+	if bytes.Contains(signature, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyRing, content, bytes.NewReader(signature), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyRing, content, bytes.NewReader(signature), nil)
+	}
+	return err
+}
@@ -0,0 +1,277 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	signedContent   = []byte("gochronize release asset\n")
+	tamperedContent = []byte("gochronize release asset!\n")
+)
+
+// minisignFixture signs content like "minisign -S", prehashed with BLAKE2b-512 if algorithm is "ED".
+func minisignFixture(t *testing.T, algorithm string, content []byte) (signature, publicKey []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyId := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	message := content
+	if algorithm == "ED" {
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+	}
+	sig := ed25519.Sign(priv, message)
+	trustedComment := "timestamp:1700000000\tfile:asset"
+	globalSig := ed25519.Sign(priv, append(append([]byte{}, sig...), trustedComment...))
+
+	key := append(append([]byte("Ed"), keyId...), pub...)
+	publicKey = []byte(fmt.Sprintf("untrusted comment: minisign public key\n%s\n", base64.StdEncoding.EncodeToString(key)))
+	sigBytes := append(append([]byte(algorithm), keyId...), sig...)
+	signature = []byte(fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(sigBytes), trustedComment, base64.StdEncoding.EncodeToString(globalSig)))
+	return signature, publicKey
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	for _, algorithm := range []string{"Ed", "ED"} {
+		t.Run(algorithm, func(t *testing.T) {
+			signature, publicKey := minisignFixture(t, algorithm, signedContent)
+			if err := VerifyMinisign(bytes.NewReader(signedContent), signature, publicKey); err != nil {
+				t.Fatalf("valid signature: %v", err)
+			}
+			if err := VerifyMinisign(bytes.NewReader(tamperedContent), signature, publicKey); err == nil {
+				t.Fatal("tampered content is verified")
+			}
+
+			_, otherKey := minisignFixture(t, algorithm, signedContent)
+			if err := VerifyMinisign(bytes.NewReader(signedContent), signature, otherKey); err == nil {
+				t.Fatal("signature is verified by another key")
+			}
+
+			tamperedComment := bytes.Replace(signature, []byte("file:asset"), []byte("file:other"), 1)
+			if err := VerifyMinisign(bytes.NewReader(signedContent), tamperedComment, publicKey); err == nil {
+				t.Fatal("tampered trusted comment is verified")
+			}
+		})
+	}
+}
+
+// cosignFixture signs content like "cosign sign-blob" with a key of keyType.
+func cosignFixture(t *testing.T, keyType string, content []byte) (signature, publicKey []byte) {
+	t.Helper()
+	var signer crypto.Signer
+	var err error
+	switch keyType {
+	case "ecdsa":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case "rsa":
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sig []byte
+	if keyType == "ed25519" {
+		sig, err = signer.Sign(rand.Reader, content, crypto.Hash(0))
+	} else {
+		sum := sha256.Sum256(content)
+		sig, err = signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), publicKey
+}
+
+func TestVerifyCosign(t *testing.T) {
+	for _, keyType := range []string{"ecdsa", "ed25519", "rsa"} {
+		t.Run(keyType, func(t *testing.T) {
+			signature, publicKey := cosignFixture(t, keyType, signedContent)
+			if err := VerifyCosign(bytes.NewReader(signedContent), signature, publicKey); err != nil {
+				t.Fatalf("valid signature: %v", err)
+			}
+			if err := VerifyCosign(bytes.NewReader(tamperedContent), signature, publicKey); err == nil {
+				t.Fatal("tampered content is verified")
+			}
+
+			_, otherKey := cosignFixture(t, keyType, signedContent)
+			if err := VerifyCosign(bytes.NewReader(signedContent), signature, otherKey); err == nil {
+				t.Fatal("signature is verified by another key")
+			}
+		})
+	}
+
+	if err := VerifyCosign(bytes.NewReader(signedContent), []byte("not base64!"), []byte("not a key")); err == nil {
+		t.Fatal("invalid key is accepted")
+	}
+}
+
+// gpgFixture signs content like "gpg --detach-sign", armored if armored is true.
+func gpgFixture(t *testing.T, armored bool, content []byte) (signature, publicKey []byte) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("gochronize", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	if armored {
+		err = openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(content), nil)
+	} else {
+		err = openpgp.DetachSign(&sig, entity, bytes.NewReader(content), nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key bytes.Buffer
+	writer, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = entity.Serialize(writer)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig.Bytes(), key.Bytes()
+}
+
+func TestVerifyGpg(t *testing.T) {
+	for _, armored := range []bool{true, false} {
+		t.Run(fmt.Sprintf("armored=%v", armored), func(t *testing.T) {
+			signature, publicKey := gpgFixture(t, armored, signedContent)
+			if err := VerifyGpg(bytes.NewReader(signedContent), signature, publicKey); err != nil {
+				t.Fatalf("valid signature: %v", err)
+			}
+			if err := VerifyGpg(bytes.NewReader(tamperedContent), signature, publicKey); err == nil {
+				t.Fatal("tampered content is verified")
+			}
+
+			_, otherKey := gpgFixture(t, armored, signedContent)
+			if err := VerifyGpg(bytes.NewReader(signedContent), signature, otherKey); err == nil {
+				t.Fatal("signature is verified by another key")
+			}
+		})
+	}
+}
+
+func TestVerifySignatureRequired(t *testing.T) {
+	signature, publicKey := minisignFixture(t, "Ed", signedContent)
+	keyFile := filepath.Join(t.TempDir(), "minisign.pub")
+	if err := os.WriteFile(keyFile, publicKey, 0644); err != nil {
+		t.Fatal(err)
+	}
+	optional := false
+	tests := []struct {
+		name       string
+		signatures map[string][]byte
+		required   *bool
+		content    []byte
+		wantErr    bool
+		wantErrors bool
+	}{
+		{name: "signed", signatures: map[string][]byte{"asset": signature}, content: signedContent},
+		{name: "tampered", signatures: map[string][]byte{"asset": signature}, content: tamperedContent, wantErr: true},
+		{name: "missing with required unset", signatures: map[string][]byte{}, content: signedContent, wantErr: true},
+		{name: "missing with required false", signatures: map[string][]byte{}, required: &optional, content: signedContent, wantErrors: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Errors = nil
+			target := &Target{User: "user", Repo: "repo"}
+			target.Verify.Signature = Signature{Type: SignatureMinisign, PublicKey: keyFile, Required: test.required}
+
+			err := verifySignature(bytes.NewReader(test.content), "asset", test.signatures, target)
+			var signatureErr *SignatureError
+			if test.wantErr != (err != nil) || (err != nil && !errors.As(err, &signatureErr)) {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if test.wantErrors != (len(Errors) != 0) {
+				t.Fatalf("errors = %v, want errors: %v", Errors, test.wantErrors)
+			}
+			if test.wantErrors && !strings.Contains(Errors[0].Msg, "No signature found") {
+				t.Fatalf("unexpected error: %s", Errors[0].Msg)
+			}
+		})
+	}
+	Errors = nil
+}
+
+func TestFetchChecksumsSigned(t *testing.T) {
+	digest := fmt.Sprintf("%x", sha256.Sum256(signedContent))
+	other := fmt.Sprintf("%x", sha256.Sum256(tamperedContent))
+	sums := []byte(digest + "  app.zip\n")
+	signature, publicKey := minisignFixture(t, "Ed", sums)
+	keyFile := filepath.Join(t.TempDir(), "minisign.pub")
+	if err := os.WriteFile(keyFile, publicKey, 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"/checksums.txt":         sums,
+		"/checksums.txt.minisig": signature,
+		// An unsigned checksum asset never overrides the signed one.
+		"/app.zip.sha256": []byte(other + "\n"),
+		"/other.sha256":   []byte(other + "\n"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { Errors = nil })
+
+	release := &Release{}
+	for _, name := range []string{"checksums.txt", "checksums.txt.minisig", "app.zip.sha256", "other.sha256"} {
+		release.Assets = append(release.Assets, Asset{Name: name, BrowserDownloadURL: server.URL + "/" + name})
+	}
+	optional := false
+	target := &Target{User: "user", Repo: "repo"}
+	target.Verify.Signature = Signature{Type: SignatureMinisign, PublicKey: keyFile, Required: &optional}
+
+	signatures := fetchSignatures(server.Client(), release, target)
+	checksums, signed := fetchChecksums(server.Client(), release, target, signatures)
+	if checksums["app.zip"] != digest || !signed["app.zip"] {
+		t.Fatalf("app.zip: checksum %s, signed %v", checksums["app.zip"], signed["app.zip"])
+	}
+	if checksums["other"] != other || signed["other"] {
+		t.Fatalf("other: checksum %s, signed %v", checksums["other"], signed["other"])
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sourceArchiveAssets returns the source archives of the release in the formats of target.SourceArchives as assets
+// named "repo-tag.format", e.g. "gochronize-v1.0.0.tar.gz". The times of the release are used as the times of them.
+func sourceArchiveAssets(release *Release, target *Target) []Asset {
+	var assets []Asset
+	for _, format := range target.SourceArchives {
+		var url string
+		switch strings.ToLower(format) {
+		case SourceArchiveZip:
+			format = SourceArchiveZip
+			url = release.ZipballUrl
+		case SourceArchiveTarGz:
+			format = SourceArchiveTarGz
+			url = release.TarballUrl
+		default:
+			msg := fmt.Sprintf("* err: Unknown source archive format: %s.", format)
+			Fprintfln(msg)
+			appendError(target.User, target.Repo, msg)
+			continue
+		}
+		if url == "" {
+			SimplifiedPrintfln("* info: No %s source archive of: %s, skip.", format, release.TagName)
+			continue
+		}
+
+		updatedAt := release.PublishedAt
+		if updatedAt == "" {
+			updatedAt = release.CreatedAt
+		}
+		assets = append(assets, Asset{
+			Name:               fmt.Sprintf("%s-%s.%s", target.Repo, strings.ReplaceAll(release.TagName, "/", "-"), format),
+			BrowserDownloadURL: url,
+			CreatedAt:          release.CreatedAt,
+			UpdatedAt:          updatedAt,
+			Source:             true,
+		})
+	}
+	return assets
+}
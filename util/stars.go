@@ -0,0 +1,124 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportStars appends a target for each repo starred by args.User to the config file, and returns the exit code.
+func ImportStars(args Args) int {
+	if args.User == "" || args.Config == "" {
+		Fprintfln("* err: --user and --config are required.")
+		return ErrorUnknownCmd
+	}
+	config := ReadFromConfig(args.Config)
+	SimplifiedLog = config.SimplifiedLog
+	Printfln("Time: %s", time.Now().Format("2006-01-02 15:04:05"))
+
+	data, err := os.ReadFile(args.Config)
+	if err != nil {
+		Fprintfln("* err: Failed to read: %s, %v", args.Config, err)
+		return ErrorIO
+	}
+	var doc yaml.Node
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		Fprintfln("* err: Failed to parse: %s, %v", args.Config, err)
+		return ErrorIO
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		Fprintfln("* err: Failed to parse: %s, not a mapping.", args.Config)
+		return ErrorIO
+	}
+
+	client := GetHttpClient(config)
+	registerTokens(config)
+	SetMaxRateLimitWait(config.MaxRateLimitWait)
+	SetCacheDir(config.CacheDir)
+	targets, err := expandTarget(client, Target{Stars: args.User, Template: &Target{Sync: args.Sync}}, config)
+	if err != nil {
+		Fprintfln("* err: Failed to list stars of: %s, %v", args.User, err)
+		return ErrorDownload
+	}
+
+	targetsNode := mappingValue(doc.Content[0], "targets")
+	if targetsNode == nil {
+		targetsNode = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		doc.Content[0].Content = append(doc.Content[0].Content, stringNode("targets", 0), targetsNode)
+	} else if targetsNode.Kind == yaml.ScalarNode && targetsNode.Tag == "!!null" {
+		targetsNode.Kind = yaml.SequenceNode
+		targetsNode.Tag = "!!seq"
+		targetsNode.Value = ""
+	} else if targetsNode.Kind != yaml.SequenceNode {
+		Fprintfln("* err: Failed to parse: %s, targets is not a list.", args.Config)
+		return ErrorIO
+	}
+
+	listed := listedTargets(config)
+	imported := 0
+	for _, target := range targets {
+		key := strings.ToLower(target.User + "/" + target.Repo)
+		if listed[key] {
+			continue
+		}
+		listed[key] = true
+		syncNode := stringNode(target.Sync, yaml.DoubleQuotedStyle)
+		syncNode.LineComment = fmt.Sprintf("Imported from stars of %s.", args.User)
+		targetsNode.Content = append(targetsNode.Content, &yaml.Node{
+			Kind: yaml.MappingNode,
+			Tag:  "!!map",
+			Content: []*yaml.Node{
+				stringNode("user", 0), stringNode(target.User, yaml.DoubleQuotedStyle),
+				stringNode("repo", 0), stringNode(target.Repo, yaml.DoubleQuotedStyle),
+				stringNode("sync", 0), syncNode,
+			},
+		})
+		Printfln("Import: %s/%s", target.User, target.Repo)
+		imported++
+	}
+	Printfln("Imported %d of %d starred repos.", imported, len(targets))
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&doc)
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		Fprintfln("* err: Failed to encode: %s, %v", args.Config, err)
+		return ErrorIO
+	}
+	if args.DryRun {
+		Printfln("%s", buf.String())
+		return Success
+	}
+	if imported == 0 {
+		return Success
+	}
+	err = WriteFileAtomic(args.Config, buf.Bytes(), 0644)
+	if err != nil {
+		Fprintfln("* err: Failed to write: %s, %v", args.Config, err)
+		return ErrorIO
+	}
+	return Success
+}
+
+// mappingValue returns the value of the key in the mapping node, or nil if it's absent.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func stringNode(value string, style yaml.Style) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: style}
+}
@@ -0,0 +1,359 @@
+package util
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// The fragments of the tag queries, refs of tags are ordered by the dates of their commits. An annotated tag points to
+// a Tag object, whose target is the commit.
+const (
+	graphqlTagRefFragment = `
+fragment tagRef on Ref {
+  name
+  target {
+    ... on Commit { committedDate }
+    ... on Tag { target { ... on Commit { committedDate } } }
+  }
+}`
+	graphqlTagRefsFragment = `
+fragment tagRefs on Repository {
+  refs(refPrefix: "refs/tags/", first: 100, after: $cursor, orderBy: {field: TAG_COMMIT_DATE, direction: DESC}) {
+    pageInfo { hasNextPage endCursor }
+    nodes { ...tagRef }
+  }
+}` + graphqlTagRefFragment
+)
+
+// Tags lists the tags of a GitHub repo as releases, with the source archives as assets.
+type Tags struct {
+	client  *http.Client
+	apiBase string
+	limit   int
+	// untilLocal stops picking the candidates at the latest tag in history, for the sync modes from the latest local.
+	untilLocal bool
+	graphql    *GitHubGraphQL
+}
+
+type githubTag struct {
+	Name       string `json:"name"`
+	ZipballUrl string `json:"zipball_url"`
+	TarballUrl string `json:"tarball_url"`
+	Commit     struct {
+		Sha string `json:"sha"`
+	} `json:"commit"`
+}
+
+type githubCommit struct {
+	Committer struct {
+		Date string `json:"date"`
+	} `json:"committer"`
+}
+
+type graphqlTagRef struct {
+	Name   string `json:"name"`
+	Target struct {
+		CommittedDate string `json:"committedDate"`
+		Target        *struct {
+			CommittedDate string `json:"committedDate"`
+		} `json:"target"`
+	} `json:"target"`
+}
+
+type graphqlTagRefs struct {
+	PageInfo graphqlPageInfo `json:"pageInfo"`
+	Nodes    []graphqlTagRef `json:"nodes"`
+}
+
+// committedDate returns the date of the commit of the tag, or "" if it doesn't point to a commit.
+func (r *graphqlTagRef) committedDate() string {
+	if r.Target.CommittedDate != "" {
+		return r.Target.CommittedDate
+	}
+	if r.Target.Target != nil {
+		return r.Target.Target.CommittedDate
+	}
+	return ""
+}
+
+func NewTags(client *http.Client, apiBase string, limit int, untilLocal bool) *Tags {
+	p := &Tags{client: client, apiBase: apiBase, limit: limit, untilLocal: untilLocal}
+	if endpoint, err := url.Parse(graphqlEndpointOf(apiBase)); err == nil && HasToken(endpoint.Host) {
+		p.graphql = NewGitHubGraphQL(client, apiBase)
+	}
+	return p
+}
+
+// tagLookupLimit returns the number of the latest tags the sync mode of the target needs, or 0 if it needs all.
+func tagLookupLimit(target *Target) int {
+	switch target.Sync {
+	case SyncLatest, SyncLatestRelease, SyncLatestPrerelease:
+		return 1
+	}
+	if target.MaxCount > 0 {
+		return target.MaxCount
+	}
+	return 0
+}
+
+// tagLookupUntilLocal reports whether the sync mode of the target only needs the tags newer than the latest local one.
+func tagLookupUntilLocal(target *Target) bool {
+	switch target.Sync {
+	case SyncFromLatestLocal, SyncReleaseFromLatestLocal, SyncPrereleaseFromLatestLocal:
+		return true
+	}
+	return false
+}
+
+// listTags lists the tags of all pages.
+func (p *Tags) listTags(user, repo string) ([]githubTag, error) {
+	var tags []githubTag
+	api := fmt.Sprintf("%s/repos/%s/%s/tags?per_page=%d", p.apiBase, user, repo, MaxPerPage)
+	for api != "" {
+		var page []githubTag
+		resp, err := GetJson(p.client, api, &page)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page...)
+		api = NextLink(resp.Header.Values("Link"), resp.Request.URL)
+	}
+	return tags, nil
+}
+
+// datedId returns the unix time of date in milliseconds plus a hash of the name under 1000.
+func datedId(date time.Time, name string) int64 {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return date.Unix()*1000 + int64(hash.Sum32()%1000)
+}
+
+// toTagRelease maps the tag onto Release with the date of its commit, and the id of the date, see datedId.
+func toTagRelease(name, committedDate, zipballUrl, tarballUrl string) (*Release, error) {
+	date, err := time.Parse(time.RFC3339, committedDate)
+	if err != nil {
+		return nil, err
+	}
+	return &Release{
+		Name:        name,
+		TagName:     name,
+		Id:          datedId(date, name),
+		CreatedAt:   committedDate,
+		PublishedAt: committedDate,
+		ZipballUrl:  zipballUrl,
+		TarballUrl:  tarballUrl,
+	}, nil
+}
+
+// toRelease maps the tag listed by the REST API onto Release, the date of its commit is taken from known if the tag
+// is in there, or requested otherwise.
+func (p *Tags) toRelease(user, repo string, tag *githubTag, known map[string]string) (*Release, error) {
+	committedDate, ok := known[tag.Name]
+	if !ok {
+		var commit githubCommit
+		_, err := GetJson(p.client, fmt.Sprintf("%s/repos/%s/%s/git/commits/%s", p.apiBase, user, repo, url.PathEscape(tag.Commit.Sha)), &commit)
+		if err != nil {
+			return nil, err
+		}
+		committedDate = commit.Committer.Date
+	}
+	return toTagRelease(tag.Name, committedDate, tag.ZipballUrl, tag.TarballUrl)
+}
+
+// fromRef maps the tag listed by the GraphQL API onto Release.
+func (p *Tags) fromRef(user, repo string, ref *graphqlTagRef) (*Release, error) {
+	committedDate := ref.committedDate()
+	if committedDate == "" {
+		return nil, fmt.Errorf("the tag doesn't point to a commit")
+	}
+	return toTagRelease(ref.Name, committedDate,
+		fmt.Sprintf("%s/repos/%s/%s/zipball/refs/tags/%s", p.apiBase, user, repo, url.PathEscape(ref.Name)),
+		fmt.Sprintf("%s/repos/%s/%s/tarball/refs/tags/%s", p.apiBase, user, repo, url.PathEscape(ref.Name)))
+}
+
+// knownTags returns the dates of the commits of the tags in history, so that they aren't requested again.
+func knownTags(user, repo string) map[string]string {
+	known := map[string]string{}
+	if localRepo := localHistoryRepo(&Target{User: user, Repo: repo, Source: SourceTags}); localRepo != nil {
+		for _, r := range localRepo.Releases {
+			if r.CreatedAt != "" {
+				known[r.TagName] = r.CreatedAt
+			}
+		}
+	}
+	return known
+}
+
+// candidates returns the tags by versionId whose commits are requested, the tags in known cost no requests.
+func (p *Tags) candidates(tags []githubTag, known map[string]string) []githubTag {
+	ids := map[string]int64{}
+	for _, tag := range tags {
+		ids[tag.Name], _ = versionId(tag.Name)
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return ids[tags[i].Name] > ids[tags[j].Name]
+	})
+
+	var result []githubTag
+	count := 0
+	reached := false
+	for _, tag := range tags {
+		_, isKnown := known[tag.Name]
+		if isKnown {
+			reached = true
+			result = append(result, tag)
+			continue
+		}
+		if (p.limit > 0 && count >= p.limit) || (p.untilLocal && reached) {
+			continue
+		}
+		count++
+		result = append(result, tag)
+	}
+	return result
+}
+
+// GetRelease returns the tags sorted by the dates of their commits, all on the first page without GraphQL.
+func (p *Tags) GetRelease(user, repo string, page int) ([]Release, int) {
+	if p.graphql != nil {
+		return p.getReleaseByGraphQL(user, repo, page)
+	}
+	if page != 1 {
+		return nil, -1
+	}
+	tags, err := p.listTags(user, repo)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to list tags, %v", err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+
+	known := knownTags(user, repo)
+	tags = p.candidates(tags, known)
+	var releases []Release
+	for i := range tags {
+		release, err := p.toRelease(user, repo, &tags[i], known)
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to get commit of tag: %s, %v", tags[i].Name, err)
+			Fprintfln(msg)
+			appendError(user, repo, msg)
+			continue
+		}
+		releases = append(releases, *release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Id > releases[j].Id
+	})
+	return releases, -1
+}
+
+func (p *Tags) getReleaseByGraphQL(user, repo string, page int) ([]Release, int) {
+	p.graphql.cursorsMutex.Lock()
+	cursor, ok := p.graphql.cursors[page]
+	p.graphql.cursorsMutex.Unlock()
+	if page != 1 && !ok {
+		msg := fmt.Sprintf("* err: Unknown page: %d.", page)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil, -1
+	}
+
+	variables := map[string]interface{}{}
+	if cursor != "" {
+		variables["cursor"] = cursor
+	}
+	repository := p.graphql.repository(user, repo, ", $cursor: String", "...tagRefs", graphqlTagRefsFragment, variables)
+	if repository == nil || repository.Refs == nil {
+		return nil, -1
+	}
+
+	var releases []Release
+	for i := range repository.Refs.Nodes {
+		release, err := p.fromRef(user, repo, &repository.Refs.Nodes[i])
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to get commit of tag: %s, %v", repository.Refs.Nodes[i].Name, err)
+			Fprintfln(msg)
+			appendError(user, repo, msg)
+			continue
+		}
+		releases = append(releases, *release)
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].Id > releases[j].Id
+	})
+	nextPage := -1
+	if repository.Refs.PageInfo.HasNextPage {
+		nextPage = page + 1
+		p.graphql.cursorsMutex.Lock()
+		p.graphql.cursors[nextPage] = repository.Refs.PageInfo.EndCursor
+		p.graphql.cursorsMutex.Unlock()
+	}
+	return releases, nextPage
+}
+
+func (p *Tags) GetLatestRelease(user, repo string) *Release {
+	releases, _ := p.GetRelease(user, repo, 1)
+	if len(releases) == 0 {
+		msg := "* err: No tags."
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	return &releases[0]
+}
+
+func (p *Tags) GetReleaseByTag(user, repo, tag string) *Release {
+	if p.graphql != nil {
+		return p.getReleaseByTagByGraphQL(user, repo, tag)
+	}
+	tags, err := p.listTags(user, repo)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to list tags, %v", err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	for i := range tags {
+		if tags[i].Name != tag {
+			continue
+		}
+		release, err := p.toRelease(user, repo, &tags[i], knownTags(user, repo))
+		if err != nil {
+			msg := fmt.Sprintf("* err: Failed to get commit of tag: %s, %v", tag, err)
+			Fprintfln(msg)
+			appendError(user, repo, msg)
+			return nil
+		}
+		return release
+	}
+	msg := fmt.Sprintf("* err: Tag not found: %s.", tag)
+	Fprintfln(msg)
+	appendError(user, repo, msg)
+	return nil
+}
+
+func (p *Tags) getReleaseByTagByGraphQL(user, repo, tag string) *Release {
+	repository := p.graphql.repository(user, repo, ", $ref: String!", "ref(qualifiedName: $ref) { ...tagRef }", graphqlTagRefFragment, map[string]interface{}{"ref": "refs/tags/" + tag})
+	if repository == nil {
+		return nil
+	}
+	if repository.Ref == nil {
+		msg := fmt.Sprintf("* err: Tag not found: %s.", tag)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	release, err := p.fromRef(user, repo, repository.Ref)
+	if err != nil {
+		msg := fmt.Sprintf("* err: Failed to get commit of tag: %s, %v", tag, err)
+		Fprintfln(msg)
+		appendError(user, repo, msg)
+		return nil
+	}
+	return release
+}
@@ -0,0 +1,236 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tagsFake serves n tags of owner/repo like GitHub, listed by name with 10 tags per page by the REST API, or by the
+// dates of their commits by the GraphQL API. The tag "v<i>" is committed on day i. The commit requests are counted
+// by commits.
+func tagsFake(t *testing.T, n int, commits *int32) *httptest.Server {
+	t.Helper()
+	date := func(i int) string {
+		return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i).Format(time.RFC3339)
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v interface{}
+		switch {
+		case r.URL.Path == "/repos/owner/repo/tags":
+			// Names are sorted as strings, so "v9" is listed before "v10".
+			var tags []githubTag
+			for i := n; i > 0; i-- {
+				tag := githubTag{Name: fmt.Sprintf("v%d", i)}
+				tag.Commit.Sha = strconv.Itoa(i)
+				tags = append(tags, tag)
+			}
+			for i := range tags {
+				for j := i + 1; j < len(tags); j++ {
+					if tags[j].Name > tags[i].Name {
+						tags[i], tags[j] = tags[j], tags[i]
+					}
+				}
+			}
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page < 1 {
+				page = 1
+			}
+			start, end := (page-1)*10, page*10
+			if end >= len(tags) {
+				end = len(tags)
+			} else {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/repos/owner/repo/tags?page=%d>; rel="next"`, server.URL, page+1))
+			}
+			v = tags[start:end]
+		case strings.HasPrefix(r.URL.Path, "/repos/owner/repo/git/commits/"):
+			atomic.AddInt32(commits, 1)
+			i, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/git/commits/"))
+			var commit githubCommit
+			commit.Committer.Date = date(i)
+			v = commit
+		case r.URL.Path == "/graphql":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, `{"message":"token is required"}`, http.StatusUnauthorized)
+				return
+			}
+			var body struct {
+				Query     string                 `json:"query"`
+				Variables map[string]interface{} `json:"variables"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			node := func(i int) map[string]interface{} {
+				return map[string]interface{}{"name": fmt.Sprintf("v%d", i), "target": map[string]interface{}{"committedDate": date(i)}}
+			}
+			if ref, ok := body.Variables["ref"].(string); ok {
+				i, _ := strconv.Atoi(strings.TrimPrefix(ref, "refs/tags/v"))
+				var r interface{}
+				if i >= 1 && i <= n {
+					// An annotated tag.
+					r = map[string]interface{}{"name": fmt.Sprintf("v%d", i), "target": map[string]interface{}{"target": map[string]interface{}{"committedDate": date(i)}}}
+				}
+				v = map[string]interface{}{"data": map[string]interface{}{"repository": map[string]interface{}{"ref": r}}}
+				break
+			}
+			start := n
+			if cursor, ok := body.Variables["cursor"].(string); ok {
+				start, _ = strconv.Atoi(cursor)
+			}
+			var nodes []interface{}
+			for i := start; i > 0 && i > start-100; i-- {
+				nodes = append(nodes, node(i))
+			}
+			pageInfo := map[string]interface{}{"hasNextPage": start > 100, "endCursor": strconv.Itoa(start - 100)}
+			refs := map[string]interface{}{"pageInfo": pageInfo, "nodes": nodes}
+			v = map[string]interface{}{"data": map[string]interface{}{"repository": map[string]interface{}{"refs": refs}}}
+		default:
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// setHistory replaces history with h until the test ends.
+func setHistory(t *testing.T, h *History) {
+	old := history
+	history = h
+	t.Cleanup(func() { history = old })
+}
+
+func tagNames(releases []Release) string {
+	var names []string
+	for _, release := range releases {
+		names = append(names, release.TagName)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestTagsGetRelease(t *testing.T) {
+	setHistory(t, &History{})
+	t.Cleanup(func() { Errors = nil })
+	var commits int32
+	server := tagsFake(t, 12, &commits)
+
+	provider := NewTags(server.Client(), server.URL, 0, false)
+	releases, page := provider.GetRelease("owner", "repo", 1)
+	if got := tagNames(releases); page != -1 || got != "v12 v11 v10 v9 v8 v7 v6 v5 v4 v3 v2 v1" {
+		t.Fatalf("tags = %s, next page: %d", got, page)
+	}
+	if commits != 12 {
+		t.Fatalf("commit requests = %d, want 12", commits)
+	}
+	if len(Errors) != 0 {
+		t.Fatalf("errors = %v", Errors)
+	}
+}
+
+func TestTagsLimit(t *testing.T) {
+	setHistory(t, &History{})
+	t.Cleanup(func() { Errors = nil })
+	var commits int32
+	server := tagsFake(t, 12, &commits)
+
+	provider := NewTags(server.Client(), server.URL, 3, false)
+	releases, _ := provider.GetRelease("owner", "repo", 1)
+	if got := tagNames(releases); got != "v12 v11 v10" {
+		t.Fatalf("tags = %s", got)
+	}
+	if commits != 3 {
+		t.Fatalf("commit requests = %d, want 3", commits)
+	}
+}
+
+func TestTagsKnown(t *testing.T) {
+	setHistory(t, &History{Repos: []HistoryRepo{
+		// The releases of the repo don't count.
+		{User: "owner", Repo: "repo", Releases: []HistoryRelease{{TagName: "v1", CreatedAt: "2000-01-01T00:00:00Z"}}},
+		{User: "owner", Repo: "repo", Source: SourceTags, Releases: []HistoryRelease{
+			{TagName: "v12", CreatedAt: "2024-01-13T00:00:00Z"},
+			{TagName: "v11", CreatedAt: "2024-01-12T00:00:00Z"},
+		}},
+	}})
+	t.Cleanup(func() { Errors = nil })
+	var commits int32
+	server := tagsFake(t, 12, &commits)
+
+	provider := NewTags(server.Client(), server.URL, 0, false)
+	releases, _ := provider.GetRelease("owner", "repo", 1)
+	if got := tagNames(releases); got != "v12 v11 v10 v9 v8 v7 v6 v5 v4 v3 v2 v1" {
+		t.Fatalf("tags = %s", got)
+	}
+	if commits != 10 {
+		t.Fatalf("commit requests = %d, want 10", commits)
+	}
+}
+
+func TestTagsUntilLocal(t *testing.T) {
+	setHistory(t, &History{Repos: []HistoryRepo{
+		{User: "owner", Repo: "repo", Source: SourceTags, Releases: []HistoryRelease{{TagName: "v10", CreatedAt: "2024-01-11T00:00:00Z"}}},
+	}})
+	t.Cleanup(func() { Errors = nil })
+	var commits int32
+	server := tagsFake(t, 12, &commits)
+
+	provider := NewTags(server.Client(), server.URL, 0, true)
+	releases, _ := provider.GetRelease("owner", "repo", 1)
+	if got := tagNames(releases); got != "v12 v11 v10" {
+		t.Fatalf("tags = %s", got)
+	}
+	if commits != 2 {
+		t.Fatalf("commit requests = %d, want 2", commits)
+	}
+}
+
+func TestTagsGraphQL(t *testing.T) {
+	t.Cleanup(func() {
+		tokens = map[string]string{}
+		Errors = nil
+	})
+	var commits int32
+	server := tagsFake(t, 150, &commits)
+	SetToken(strings.TrimPrefix(server.URL, "http://"), "Bearer secret")
+
+	provider := NewTags(server.Client(), server.URL, 0, false)
+	releases, page := provider.GetRelease("owner", "repo", 1)
+	if len(releases) != 100 || releases[0].TagName != "v150" || page != 2 {
+		t.Fatalf("tags = %s, next page: %d", tagNames(releases), page)
+	}
+	if releases[0].TarballUrl != server.URL+"/repos/owner/repo/tarball/refs/tags/v150" {
+		t.Fatalf("tarball url = %s", releases[0].TarballUrl)
+	}
+	releases, page = provider.GetRelease("owner", "repo", page)
+	if len(releases) != 50 || releases[0].TagName != "v50" || page != -1 {
+		t.Fatalf("tags = %s, next page: %d", tagNames(releases), page)
+	}
+
+	if release := provider.GetLatestRelease("owner", "repo"); release == nil || release.TagName != "v150" {
+		t.Fatalf("latest tag = %+v", release)
+	}
+	if release := provider.GetReleaseByTag("owner", "repo", "v7"); release == nil || release.CreatedAt != "2024-01-08T00:00:00Z" {
+		t.Fatalf("tag = %+v", release)
+	}
+	if commits != 0 {
+		t.Fatalf("commit requests = %d, want 0", commits)
+	}
+	if len(Errors) != 0 {
+		t.Fatalf("errors = %v", Errors)
+	}
+
+	if release := provider.GetReleaseByTag("owner", "repo", "v999"); release != nil {
+		t.Fatalf("missing tag = %+v", release)
+	}
+	if len(Errors) != 1 || Errors[0].Msg != "* err: Tag not found: v999." {
+		t.Fatalf("errors = %v", Errors)
+	}
+}
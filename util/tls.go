@@ -0,0 +1,56 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsConfigOf returns the TLS config of [tls], or nil if it's left with the defaults.
+func tlsConfigOf(config *Config) *tls.Config {
+	settings := config.TLS
+	if settings.CaFile == "" && settings.ClientCert == "" && settings.ClientKey == "" && !settings.InsecureSkipVerify {
+		return nil
+	}
+	tlsConfig := &tls.Config{}
+
+	if settings.CaFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			Fprintfln("* info: Failed to load the system roots, only %s is trusted, %v", settings.CaFile, err)
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(settings.CaFile)
+		if err == nil && !pool.AppendCertsFromPEM(pem) {
+			err = fmt.Errorf("no PEM certificates")
+		}
+		if err != nil {
+			Fprintfln("Failed to load ca_file: %s, %v", settings.CaFile, err)
+			os.Exit(Error)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if settings.ClientCert != "" || settings.ClientKey != "" {
+		if settings.ClientCert == "" || settings.ClientKey == "" {
+			Fprintfln("Failed to load client certificate, both client_cert and client_key are required.")
+			os.Exit(Error)
+		}
+		cert, err := tls.LoadX509KeyPair(settings.ClientCert, settings.ClientKey)
+		if err != nil {
+			Fprintfln("Failed to load client certificate: %s, %v", settings.ClientCert, err)
+			os.Exit(Error)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if settings.InsecureSkipVerify {
+		Fprintfln("********************************************")
+		Fprintfln("* warning: TLS certificate verification is DISABLED by insecure_skip_verify, any host can impersonate")
+		Fprintfln("* warning: the API and download servers, and the tokens are exposed. Use ca_file if possible.")
+		Fprintfln("********************************************")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return tlsConfig
+}
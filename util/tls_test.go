@@ -0,0 +1,119 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePem writes the PEM block of bytes to a file of the test, and returns its path.
+func writePem(t *testing.T, name, blockType string, bytes []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// caFileOf writes the certificate of the httptest TLS server as a ca_file.
+func caFileOf(t *testing.T, server *httptest.Server) string {
+	return writePem(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+}
+
+// clientCertFixture creates a self-signed client certificate of commonName, and writes it as client_cert and
+// client_key.
+func clientCertFixture(t *testing.T, commonName string) (cert *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, writePem(t, "client.pem", "CERTIFICATE", der), writePem(t, "client.key", "EC PRIVATE KEY", keyDer)
+}
+
+func getStatus(config *Config, url string) error {
+	resp, err := GetHttpClient(config).Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Url: url}
+	}
+	return nil
+}
+
+func TestTLSCaFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	if err := getStatus(&Config{}, server.URL); err == nil {
+		t.Fatal("the certificate of the server is trusted without ca_file")
+	}
+	if err := getStatus(&Config{TLS: TLS{CaFile: caFileOf(t, server)}}, server.URL); err != nil {
+		t.Fatalf("ca_file: %v", err)
+	}
+	if err := getStatus(&Config{TLS: TLS{InsecureSkipVerify: true}}, server.URL); err != nil {
+		t.Fatalf("insecure_skip_verify: %v", err)
+	}
+}
+
+func TestTLSClientCert(t *testing.T) {
+	cert, certFile, keyFile := clientCertFixture(t, "gochronize")
+	var presented string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	caFile := caFileOf(t, server)
+
+	if err := getStatus(&Config{TLS: TLS{CaFile: caFile}}, server.URL); err == nil {
+		t.Fatal("the server accepts requests without the client certificate")
+	}
+	if err := getStatus(&Config{TLS: TLS{ClientCert: certFile, ClientKey: keyFile}}, server.URL); err == nil {
+		t.Fatal("the certificate of the server is trusted without ca_file")
+	}
+	err := getStatus(&Config{TLS: TLS{CaFile: caFile, ClientCert: certFile, ClientKey: keyFile}}, server.URL)
+	if err != nil {
+		t.Fatalf("ca_file and client certificate: %v", err)
+	}
+	if presented != "gochronize" {
+		t.Fatalf("presented client certificate: %q", presented)
+	}
+}
@@ -0,0 +1,66 @@
+package util
+
+import "sync"
+
+// RunParallel calls fn for every index in [0, n) with at most workers goroutines at the same time.
+func RunParallel(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// downloadSlots limits the assets downloaded at the same time across all targets, it's unlimited if it's nil.
+var downloadSlots chan struct{} = nil
+
+// SetMaxDownloads sets the max assets downloaded at the same time across all targets, so the targets synchronized in
+// parallel never download more than n assets in total.
+func SetMaxDownloads(n int) {
+	if n < 1 {
+		n = 1
+	}
+	downloadSlots = make(chan struct{}, n)
+}
+
+// maxDownloads returns the max assets downloaded at the same time across all targets, which is [concurrency] of
+// global, or the largest [concurrency] of the targets if it's greater.
+func maxDownloads(config *Config) int {
+	n := config.Concurrency
+	for _, target := range config.Targets {
+		if target.Concurrency > n {
+			n = target.Concurrency
+		}
+	}
+	return n
+}
+
+// acquireDownload blocks until a download slot is free, the slot is freed by the returned func.
+func acquireDownload() func() {
+	slots := downloadSlots
+	if slots == nil {
+		return func() {}
+	}
+	slots <- struct{}{}
+	return func() {
+		<-slots
+	}
+}